@@ -116,7 +116,7 @@ func (b *bitcoinKeys) getAddress() []byte {
 	//1.ripemd160(sha256(publickey))
 	ripPubKey := generatePublicKeyHash(b.PublicKey)
 	//2.最前面添加一个字节的版本信息获得 versionPublickeyHash
-	versionPublickeyHash := append([]byte{version}, ripPubKey[:]...)
+	versionPublickeyHash := append([]byte{ActiveParams.PubKeyHashAddrID}, ripPubKey[:]...)
 	//3.sha256(sha256(versionPublickeyHash))  取最后四个字节的值
 	tailHash := checkSumHash(versionPublickeyHash)
 	//4.拼接最终hash versionPublickeyHash + checksumHash
@@ -180,6 +180,10 @@ func IsVaildBitcoinAddress(address string) bool {
 	if len(fullHash) != 25 {
 		return false
 	}
+	//只接受普通地址与多重签名地址
+	if fullHash[0] != ActiveParams.PubKeyHashAddrID && fullHash[0] != ActiveParams.ScriptHashAddrID {
+		return false
+	}
 	prefixHash := fullHash[:len(fullHash)-checkSum]
 	tailHash := fullHash[len(fullHash)-checkSum:]
 	tailHash2 := checkSumHash(prefixHash)
@@ -202,7 +206,7 @@ func GetAddressFromPublicKey(publickey []byte) string {
 //通过公钥信息获得地址
 func GetAddressFromPublicKeyHash(publickeyHash []byte) string {
 	//2.最前面添加一个字节的版本信息获得 versionPublickeyHash
-	versionPublickeyHash := append([]byte{version}, publickeyHash[:]...)
+	versionPublickeyHash := append([]byte{ActiveParams.PubKeyHashAddrID}, publickeyHash[:]...)
 	//3.sha256(sha256(versionPublickeyHash))  取最后四个字节的值
 	tailHash := checkSumHash(versionPublickeyHash)
 	//4.拼接最终hash versionPublickeyHash + checksumHash
@@ -212,13 +216,37 @@ func GetAddressFromPublicKeyHash(publickeyHash []byte) string {
 	return string(address)
 }
 
+//通过赎回脚本hash获得多重签名(P2SH)地址
+func GetAddressFromScriptHash(scriptHash []byte) string {
+	versionScriptHash := append([]byte{ActiveParams.ScriptHashAddrID}, scriptHash...)
+	tailHash := checkSumHash(versionScriptHash)
+	return string(util.Base58Encode(append(versionScriptHash, tailHash...)))
+}
+
+//是否为多重签名(P2SH)地址
+func isScriptHashAddress(address string) bool {
+	fullHash := util.Base58Decode([]byte(address))
+	return len(fullHash) > 0 && fullHash[0] == ActiveParams.ScriptHashAddrID
+}
+
+//根据地址生成锁定脚本:普通地址生成P2PKH脚本,多重签名地址生成P2SH脚本
+func NewScriptForAddress(address string) []byte {
+	hash := getPublicKeyHashFromAddress(address)
+	if isScriptHashAddress(address) {
+		return NewP2SHScript(hash)
+	}
+	return NewP2PKHScript(hash)
+}
+
 //使用私钥进行数字签名
 func ellipticCurveSign(privKey *ecdsa.PrivateKey, hash []byte) []byte {
 	r, s, err := ecdsa.Sign(rand.Reader, privKey, hash)
 	if err != nil {
 		log.Panic("EllipticCurveSign:", err)
 	}
-	signature := append(r.Bytes(), s.Bytes()...)
+	//r与s补齐到32字节,验证时按长度的一半拆分签名
+	signature := paddedAppend(32, []byte{}, r.Bytes())
+	signature = paddedAppend(32, signature, s.Bytes())
 	return signature
 }
 
@@ -237,7 +265,7 @@ func ellipticCurveVerify(pubKey []byte, signature []byte, hash []byte) bool {
 	x.SetBytes(pubKey[:(keyLen / 2)])
 	y.SetBytes(pubKey[(keyLen / 2):])
 	curve := elliptic.P256()
-	rawPubKey := ecdsa.PublicKey{curve, &x, &y}
+	rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
 	//传入公钥，要验证的信息，以及签名
 	if ecdsa.Verify(&rawPubKey, hash, &r, &s) == false {
 		return false
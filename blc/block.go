@@ -2,54 +2,68 @@ package block
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"github.com/corgi-kx/blockchain_golang/util"
 	log "github.com/corgi-kx/logcustom"
-	"math/big"
 	"time"
 )
 
 type Block struct {
-	//上一个区块的hash
-	PreHash []byte
+	//区块头
+	BlockHeader
 	//数据data
 	Transactions []Transaction
-	//时间戳
-	TimeStamp int64
-	//区块高度
-	Height int
-	//随机数
-	Nonce int64
 	//本区块hash
 	Hash []byte
 }
 
-//进行挖矿来生成区块
-func mineBlock(transaction []Transaction, preHash []byte, height int) (*Block, error) {
+//区块大小(字节):区块头拼接数据的长度加上全部交易的大小
+func (b *Block) Size() int {
+	size := len(b.jointData(b.Nonce))
+	for i := range b.Transactions {
+		size += b.Transactions[i].Size()
+	}
+	return size
+}
+
+//生成待封装的区块:填写区块头,并由共识引擎填写难度值等共识相关的字段
+func newBlock(bc *blockchain, transaction []Transaction, preHash []byte, height int) (*Block, error) {
 	timeStamp := time.Now().Unix()
-	//hash数据+时间戳+上一个区块hash
-	block := Block{preHash, transaction, timeStamp, height, 0, nil}
-	pow := NewProofOfWork(&block)
-	nonce, hash, err := pow.run()
+	//版本号+上一个区块hash+交易默克尔根+时间戳+难度值+高度组成区块头
+	header := BlockHeader{blockVersion, preHash, calcMerkleRoot(transaction), timeStamp, 0, 0, height, nil, nil}
+	block := Block{header, transaction, nil}
+	err := Engine.Prepare(bc, &block.BlockHeader)
 	if err != nil {
 		return nil, err
 	}
-	block.Nonce = nonce
-	block.Hash = hash[:]
-	log.Info("pow verify : ", pow.Verify())
-	log.Infof("已生成新的区块,区块高度为%d", block.Height)
 	return &block, nil
 }
 
-//生成创世区块
-func newGenesisBlock(transaction []Transaction) *Block {
-	//创世区块的上一个块hash默认设置成下面的样子
-	preHash := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-	//生成创世区块
-	genesisBlock, err := mineBlock(transaction, preHash, 1)
+//通过共识引擎封装区块(pow进行挖矿,poa进行签名),ctx被取消时终止出块
+func sealBlock(ctx context.Context, bc *blockchain, block *Block) error {
+	err := Engine.Seal(ctx, bc, block)
 	if err != nil {
-		log.Error(err)
+		return err
+	}
+	log.Infof("已生成新的区块,区块高度为%d", block.Height)
+	return nil
+}
+
+//计算交易数据的默克尔根
+func calcMerkleRoot(transaction []Transaction) []byte {
+	//拼接成交易数组
+	transData := [][]byte{}
+	for _, v := range transaction {
+		tBytes := v.merkleLeaf() //这里为什么要用到自己写的方法，而不是gob序列化，是因为gob同样的数据序列化后的字节数组有可能不一致，无法用于hash验证
+		transData = append(transData, tBytes)
 	}
-	return genesisBlock
+	//获取交易数据的根默克尔节点
+	mt := util.NewMerkelTree(transData)
+	if mt.MerkelRootNode == nil {
+		return nil
+	}
+	return mt.MerkelRootNode.Data
 }
 
 // 将Block对象序列化成[]byte
@@ -73,10 +87,5 @@ func (v *Block) Deserialize(d []byte) {
 }
 
 func isGenesisBlock(block *Block) bool {
-	var hashInt big.Int
-	hashInt.SetBytes(block.PreHash)
-	if big.NewInt(0).Cmp(&hashInt) == 0 {
-		return true
-	}
-	return false
+	return isGenesisHeader(&block.BlockHeader)
 }
@@ -0,0 +1,218 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/util"
+	log "github.com/corgi-kx/logcustom"
+	"math/big"
+	"sort"
+	"time"
+)
+
+//区块头,区块hash只由区块头计算得出,交易通过默克尔根与区块头绑定
+type BlockHeader struct {
+	//区块版本号
+	Version int32
+	//上一个区块的hash
+	PreHash []byte
+	//交易数据的默克尔根
+	MerkleRoot []byte
+	//时间戳
+	TimeStamp int64
+	//挖矿难度值(compact格式)
+	Bits uint32
+	//随机数
+	Nonce int64
+	//区块高度
+	Height int
+	//出块者公钥(poa共识),不参与区块hash的计算
+	Signer []byte
+	//出块者对区块hash的签名(poa共识),不参与区块hash的计算
+	Signature []byte
+}
+
+//将区块头中的各个字段依次拼接成字节数组(nonce单独传入,便于挖矿时替换),出块者签名不参与拼接
+func (h *BlockHeader) jointData(nonce int64) []byte {
+	return bytes.Join([][]byte{
+		util.Int64ToBytes(int64(h.Version)),
+		h.PreHash,
+		h.MerkleRoot,
+		util.Int64ToBytes(h.TimeStamp),
+		util.Int64ToBytes(int64(h.Bits)),
+		util.Int64ToBytes(nonce),
+		util.Int64ToBytes(int64(h.Height))},
+		[]byte(""))
+}
+
+//获取nonce为0时的区块头拼接数据,以及nonce在其中的偏移量,挖矿时只需替换该位置的8个字节
+func (h *BlockHeader) WorkData() ([]byte, int) {
+	data := h.jointData(0)
+	//nonce之后只有区块高度一个字段
+	return data, len(data) - 16
+}
+
+//计算区块头hash
+func (h *BlockHeader) CalcHash() []byte {
+	hash := sha256.Sum256(h.jointData(h.Nonce))
+	return hash[:]
+}
+
+// 将BlockHeader对象序列化成[]byte
+func (h *BlockHeader) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	err := encoder.Encode(h)
+	if err != nil {
+		panic(err)
+	}
+	return result.Bytes()
+}
+
+func (h *BlockHeader) Deserialize(d []byte) {
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(h)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+func isGenesisHeader(h *BlockHeader) bool {
+	var hashInt big.Int
+	hashInt.SetBytes(h.PreHash)
+	return big.NewInt(0).Cmp(&hashInt) == 0
+}
+
+//通过hash获取区块头,区块头仓库中没有的话(旧版本数据库)从区块中取出
+func (bc *blockchain) getHeader(hash []byte) *BlockHeader {
+	headerBytes := bc.BD.View(hash, database.HeaderBucket)
+	if len(headerBytes) != 0 {
+		header := &BlockHeader{}
+		header.Deserialize(headerBytes)
+		return header
+	}
+	block := bc.getBlock(hash)
+	if block == nil {
+		return nil
+	}
+	return &block.BlockHeader
+}
+
+//计算区块头及其之前共medianTimeBlocks个区块时间戳的中位数(过去中位时间),
+//出块者无法通过调整单个区块的时间戳改变它,时间锁以它为准
+func (bc *blockchain) calcPastMedianTime(header *BlockHeader) int64 {
+	timeStamps := []int64{}
+	for header != nil && len(timeStamps) < medianTimeBlocks {
+		timeStamps = append(timeStamps, header.TimeStamp)
+		if isGenesisHeader(header) {
+			break
+		}
+		header = bc.getHeader(header.PreHash)
+	}
+	return medianTimeStamp(timeStamps)
+}
+
+//获取最新区块的过去中位时间,没有区块时返回0
+func (bc *blockchain) tipPastMedianTime() int64 {
+	tipHash := bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)
+	if len(tipHash) == 0 {
+		return 0
+	}
+	return bc.calcPastMedianTime(bc.getHeader(tipHash))
+}
+
+//时间戳的中位数
+func medianTimeStamp(timeStamps []int64) int64 {
+	if len(timeStamps) == 0 {
+		return 0
+	}
+	sorted := append([]int64{}, timeStamps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+//判断本地是否已存在该区块头
+func (bc *blockchain) HasHeader(hash []byte) bool {
+	return bc.getHeader(hash) != nil
+}
+
+//校验一组连续的区块头,通过校验的区块头存入区块头仓库,并返回这些区块头的hash
+func (bc *blockchain) AddHeaders(headers []BlockHeader) ([][]byte, error) {
+	hashes := [][]byte{}
+	for i := range headers {
+		header := &headers[i]
+		hash := header.CalcHash()
+		if bc.HasHeader(hash) {
+			hashes = append(hashes, hash)
+			continue
+		}
+		err := bc.checkHeader(header)
+		if err != nil {
+			return hashes, fmt.Errorf("区块头%x(高度%d)校验失败:%s", hash, header.Height, err)
+		}
+		parentWork := big.NewInt(0)
+		if !isGenesisHeader(header) {
+			parentWork = bc.getChainWork(header.PreHash)
+		}
+		chainWork := new(big.Int).Add(parentWork, Engine.CalcWork(header))
+		bc.BD.Put(hash, header.Serialize(), database.HeaderBucket)
+		bc.BD.Put(hash, chainWork.Bytes(), database.WorkBucket)
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+//区块头的上下文校验:创世区块必须与网络参数一致,其余区块头的上一个区块头存在、高度连续、时间戳合理且满足共识规则
+func (bc *blockchain) checkHeader(header *BlockHeader) error {
+	//创世区块由网络参数固定,不需要经过共识校验
+	if isGenesisHeader(header) {
+		if !isActiveGenesis(header) {
+			return fmt.Errorf("创世区块与当前网络(%s)的创世区块不一致", ActiveParams.Name)
+		}
+		return nil
+	}
+	parent := bc.getHeader(header.PreHash)
+	if parent == nil {
+		return errors.New("找不到上一个区块头")
+	}
+	if header.Height != parent.Height+1 {
+		return errors.New("区块高度不连续")
+	}
+	if header.TimeStamp < parent.TimeStamp {
+		return errors.New("时间戳早于上一个区块")
+	}
+	//时间戳必须晚于过去中位时间,避免大量区块头使用同一个时间戳
+	if header.TimeStamp <= bc.calcPastMedianTime(parent) {
+		return errors.New("时间戳不晚于过去中位时间")
+	}
+	if header.TimeStamp > time.Now().Unix()+maxFutureBlockTime {
+		return errors.New("时间戳超前当前时间过多")
+	}
+	return Engine.VerifyHeader(bc, header)
+}
+
+//从对方传来的区块定位器中找到与本地主链的分叉点,返回分叉点之后的主链区块头(最多max个)
+func (bc *blockchain) GetHeadersByLocator(locator [][]byte, max int) []BlockHeader {
+	forkHeight := 0
+	for _, hash := range locator {
+		if bc.IsInMainChain(hash) {
+			forkHeight = bc.getHeader(hash).Height
+			break
+		}
+	}
+	//通过主链高度索引按高度从低到高收集分叉点之后的区块头
+	headers := []BlockHeader{}
+	for height := forkHeight + 1; len(headers) < max; height++ {
+		hash := bc.GetBlockHashByHeight(height)
+		if hash == nil {
+			break
+		}
+		headers = append(headers, *bc.getHeader(hash))
+	}
+	return headers
+}
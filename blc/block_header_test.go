@@ -0,0 +1,96 @@
+package block
+
+import (
+	"bytes"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"testing"
+)
+
+func TestHeadersByLocator(t *testing.T) {
+	t.Log("测试根据区块定位器返回分叉点之后的主链区块头")
+	{
+		bc := newTestChain(t)
+		blocks := []*Block{ActiveParams.GenesisBlock()}
+		var locator [][]byte
+		for i := 0; i < 5; i++ {
+			b := mineTestBlock(t, bc, blocks[len(blocks)-1], nil, nil, 'a')
+			addTestBlock(t, bc, b)
+			blocks = append(blocks, b)
+			//对方只同步到了高度3
+			if b.Height == 3 {
+				locator = bc.GetBlockLocator()
+			}
+		}
+		headers := bc.GetHeadersByLocator(locator, 100)
+		if len(headers) != 3 {
+			t.Fatalf("\t返回的区块头数量不正确:%d", len(headers))
+		}
+		for i, h := range headers {
+			if !bytes.Equal(h.CalcHash(), blocks[3+i].Hash) {
+				t.Fatalf("\t第%d个区块头不正确", i+1)
+			}
+		}
+		if headers := bc.GetHeadersByLocator(locator, 2); len(headers) != 2 || headers[1].Height != 5 {
+			t.Fatalf("\t返回的区块头没有按上限截取")
+		}
+		//定位器中不认识的hash被跳过,从创世区块之后开始返回
+		headers = bc.GetHeadersByLocator([][]byte{[]byte("unknown"), blocks[0].Hash}, 100)
+		if len(headers) != 5 || headers[0].Height != 2 {
+			t.Fatalf("\t从创世区块之后返回的区块头不正确")
+		}
+		t.Log("\t区块头返回正确")
+	}
+	t.Log("测试旧版本数据库没有主链高度索引时重建")
+	{
+		bc := newTestChain(t)
+		b := mineTestBlock(t, bc, ActiveParams.GenesisBlock(), nil, nil, 'a')
+		addTestBlock(t, bc, b)
+		bc.BD.DeleteBucket(database.HeightBucket)
+		if bc.IsInMainChain(b.Hash) {
+			t.Fatalf("\t没有高度索引时不应判断为主链区块")
+		}
+		bc.RecoverHeightIndex()
+		if !bc.IsInMainChain(b.Hash) || !bytes.Equal(bc.GetBlockHashByHeight(1), ActiveParams.GenesisBlock().Hash) {
+			t.Fatalf("\t重建后的高度索引不正确")
+		}
+		t.Log("\t高度索引重建正确")
+	}
+}
+
+func TestAddHeaders(t *testing.T) {
+	t.Log("测试区块头的校验:接在已知区块头之后的区块头被存入,不连续或时间戳不正确的区块头被拒绝")
+	{
+		bc := newTestChain(t)
+		genesis := ActiveParams.GenesisBlock()
+		b1 := mineTestBlock(t, bc, genesis, nil, nil, 'a')
+		hashes, err := bc.AddHeaders([]BlockHeader{b1.BlockHeader})
+		if err != nil || len(hashes) != 1 || !bc.HasHeader(b1.Hash) {
+			t.Fatalf("\t正确的区块头没有被存入:%v", err)
+		}
+		//只有区块头时也能在其后继续校验区块头
+		b2 := mineTestBlock(t, bc, b1, nil, nil, 'a')
+		if _, err := bc.AddHeaders([]BlockHeader{b2.BlockHeader}); err != nil {
+			t.Fatalf("\t接在区块头之后的区块头没有被存入:%s", err)
+		}
+		if bc.IsInMainChain(b1.Hash) {
+			t.Fatalf("\t只有区块头的区块不应在主链上")
+		}
+		orphan := b2.BlockHeader
+		orphan.PreHash = []byte("unknown")
+		if _, err := bc.AddHeaders([]BlockHeader{orphan}); err == nil || bc.HasHeader(orphan.CalcHash()) {
+			t.Fatalf("\t上一个区块头不存在的区块头被存入")
+		}
+		gap := b2.BlockHeader
+		gap.Height = 5
+		if _, err := bc.AddHeaders([]BlockHeader{gap}); err == nil {
+			t.Fatalf("\t高度不连续的区块头被存入")
+		}
+		//时间戳与过去中位时间相同
+		stale := b1.BlockHeader
+		stale.TimeStamp = genesis.TimeStamp
+		if _, err := bc.AddHeaders([]BlockHeader{stale}); err == nil {
+			t.Fatalf("\t时间戳不晚于过去中位时间的区块头被存入")
+		}
+		t.Log("\t区块头校验正确")
+	}
+}
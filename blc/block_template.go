@@ -0,0 +1,96 @@
+/*
+	区块模板:由交易生成待封装的区块,内置挖矿与外部矿工(getblocktemplate)使用同一份模板,
+	外部矿工只需要替换区块头中的nonce(以及时间戳)即可提交区块
+*/
+package block
+
+import (
+	"errors"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/util"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//根据交易生成接在当前最新区块之后的区块模板:剔除输入无效的交易并统计手续费,
+//并在最前面加入支付出块奖励与手续费的奖励交易(rewardAddress为空时奖励作废),最后由共识引擎填写难度值
+//模板中的区块还没有nonce与区块hash
+func (bc *blockchain) NewBlockTemplate(transaction []Transaction, rewardAddress string) (*Block, error) {
+	preBlock := bc.getBlock(bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket))
+	if preBlock == nil {
+		return nil, errors.New("NewBlockTemplate err : 还没有生成创世区块")
+	}
+	height := preBlock.Height + 1
+	transaction = limitBlockTransactions(transaction)
+	//统计交易手续费,剔除输入无效的交易
+	fees := bc.collectFees(&transaction, height)
+	rewardTs := bc.CreataRewardTransaction(rewardAddress, height, fees)
+	//奖励交易放在第一位,它在默克尔树中的兄弟节点都在右边,矿池只需下发一组默克尔分支
+	transaction = append([]Transaction{rewardTs}, transaction...)
+	return newBlock(bc, transaction, preBlock.Hash, height)
+}
+
+//按顺序选取交易,直到交易总大小或交易数量达到共识上限(为区块头与奖励交易预留空间)
+//交易池给出的交易父交易在前,截断后不会出现缺少父交易的子交易
+func limitBlockTransactions(transaction []Transaction) []Transaction {
+	size := 0
+	for i := range transaction {
+		size += transaction[i].Size()
+		if size > MaxBlockSize-BlockReservedSize || i+1 >= MaxBlockTransactions {
+			log.Warnf("交易总大小或数量超过区块上限,只打包前%d笔交易", i)
+			return transaction[:i]
+		}
+	}
+	return transaction
+}
+
+//拆分奖励交易的规范序列化数据:coinb1 + 额外随机数(extraNonceSize字节) + coinb2,供矿池下发给矿工
+//奖励交易的签名位置为 区块高度(8字节) + 额外随机数,矿工拼接出完整数据后两次sha256即为奖励交易hash
+func (b *Block) SplitCoinbase(extraNonceSize int) ([]byte, []byte, error) {
+	if extraNonceSize > maxExtraNonceSize {
+		return nil, nil, errors.New("SplitCoinbase err : 额外随机数过长")
+	}
+	if len(b.Transactions) == 0 || !b.Transactions[0].IsCoinbase() {
+		return nil, nil, errors.New("SplitCoinbase err : 区块的第一笔交易不是奖励交易")
+	}
+	ts := b.Transactions[0]
+	ts.Vint = []TXInput{ts.Vint[0]}
+	ts.setExtraNonce(b.Height, make([]byte, extraNonceSize))
+	data := ts.canonicalBytes()
+	//输入数量(8) + 空交易hash(8) + 索引(8) + 签名长度(8) + 区块高度(8)
+	offset := 8 + 8 + 8 + 8 + 8
+	return data[:offset], data[offset+extraNonceSize:], nil
+}
+
+//获取第一笔交易(奖励交易)到默克尔根的默克尔分支,奖励交易的兄弟节点都在右边
+func (b *Block) CoinbaseMerkleBranch() ([][]byte, error) {
+	leaves := [][]byte{}
+	for i := range b.Transactions {
+		leaves = append(leaves, b.Transactions[i].merkleLeaf())
+	}
+	proof, err := util.NewMerkelTree(leaves).GenerateProof(0)
+	if err != nil {
+		return nil, err
+	}
+	branch := [][]byte{}
+	for _, p := range proof {
+		branch = append(branch, p.Hash)
+	}
+	return branch, nil
+}
+
+//设置奖励交易中的额外随机数并重新计算默克尔根,不会修改与其他区块共用的交易数据
+func (b *Block) SetCoinbaseExtraNonce(extraNonce []byte) error {
+	if len(extraNonce) > maxExtraNonceSize {
+		return errors.New("SetCoinbaseExtraNonce err : 额外随机数过长")
+	}
+	b.Transactions = append([]Transaction{}, b.Transactions...)
+	for i := range b.Transactions {
+		if b.Transactions[i].IsCoinbase() {
+			b.Transactions[i].Vint = []TXInput{b.Transactions[i].Vint[0]}
+			b.Transactions[i].setExtraNonce(b.Height, extraNonce)
+			b.MerkleRoot = calcMerkleRoot(b.Transactions)
+			return nil
+		}
+	}
+	return errors.New("SetCoinbaseExtraNonce err : 区块中没有奖励交易")
+}
@@ -0,0 +1,162 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"github.com/corgi-kx/blockchain_golang/util"
+	"strings"
+	"testing"
+)
+
+func TestCoinbaseSplit(t *testing.T) {
+	t.Log("测试矿工由coinb1、额外随机数、coinb2与默克尔分支计算出的默克尔根与区块一致")
+	{
+		b := newTestTemplate(BigToCompact(ActiveParams.PowLimit))
+		for i := 0; i < 4; i++ {
+			ts := Transaction{nil, []TXInput{{[]byte{byte(i)}, 0, newP2PKHSigScript([]byte("sig"), []byte("pubkey")), 0}}, []TXOutput{{1, []byte("to")}}, 0}
+			ts.hash()
+			b.Transactions = append(b.Transactions, ts)
+		}
+		b.MerkleRoot = calcMerkleRoot(b.Transactions)
+		coinb1, coinb2, err := b.SplitCoinbase(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		branch, err := b.CoinbaseMerkleBranch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		extraNonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+		solved := *b
+		err = solved.SetCoinbaseExtraNonce(extraNonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//按矿工的方式计算奖励交易hash
+		data := append(append(append([]byte{}, coinb1...), extraNonce...), coinb2...)
+		first := sha256.Sum256(data)
+		txHash := sha256.Sum256(first[:])
+		if !bytes.Equal(txHash[:], solved.Transactions[0].TxHash) {
+			t.Fatalf("\t由coinb1与coinb2计算出的奖励交易hash不正确")
+		}
+		proof := []util.MerkelProofNode{}
+		for _, h := range branch {
+			proof = append(proof, util.MerkelProofNode{Hash: h, IsLeft: false})
+		}
+		if !util.VerifyProof(solved.Transactions[0].merkleLeaf(), proof, solved.MerkleRoot) {
+			t.Fatalf("\t由默克尔分支计算出的默克尔根不正确")
+		}
+		//原模板不能被修改
+		if !bytes.Equal(b.MerkleRoot, calcMerkleRoot(b.Transactions)) || bytes.Equal(b.MerkleRoot, solved.MerkleRoot) {
+			t.Fatalf("\t设置额外随机数时修改了原区块模板")
+		}
+		t.Log("\t默克尔根计算正确")
+	}
+}
+
+func TestBlockSizeLimits(t *testing.T) {
+	t.Log("测试交易的输入输出数量与区块中交易的总大小受共识上限限制")
+	{
+		vOut := make([]TXOutput, maxTxOutputs+1)
+		for i := range vOut {
+			vOut[i] = TXOutput{1, []byte("to")}
+		}
+		ts := Transaction{nil, []TXInput{{[]byte("prev"), 0, nil, 0}}, vOut, 0}
+		ts.hash()
+		if err, ok := checkTransactionSanity(&ts).(*ValidationError); !ok || err.Reason != RejectOversize {
+			t.Fatalf("\t输出数量超过上限的交易通过了校验")
+		}
+		ts.Vout = vOut[:maxTxOutputs]
+		ts.hash()
+		if err := checkTransactionSanity(&ts); err != nil {
+			t.Fatalf("\t输出数量未超过上限的交易没有通过校验:%s", err)
+		}
+		//每笔交易略小于区块可用空间的三分之一,只能打包前3笔
+		big := Transaction{nil, []TXInput{{[]byte("prev"), 0, make([]byte, (MaxBlockSize-BlockReservedSize)/3-100), 0}}, []TXOutput{{1, []byte("to")}}, 0}
+		tss := limitBlockTransactions([]Transaction{big, big, big, big, big})
+		if len(tss) != 3 {
+			t.Fatalf("\t超过区块大小上限时打包的交易数量不正确:%d", len(tss))
+		}
+		t.Log("\t大小上限校验正确")
+	}
+}
+
+func TestMoneyAndCoinbaseRules(t *testing.T) {
+	t.Log("测试金额上限与奖励交易的位置")
+	{
+		ts := Transaction{nil, []TXInput{{[]byte("prev"), 0, nil, 0}}, []TXOutput{{MaxMoney + 1, []byte("to")}}, 0}
+		ts.hash()
+		if checkTransactionSanity(&ts) == nil {
+			t.Fatalf("\t输出金额超过上限的交易通过了校验")
+		}
+		//每个输出都不超过上限,但总额溢出
+		ts.Vout = []TXOutput{{MaxMoney, []byte("to")}, {MaxMoney, []byte("to")}}
+		ts.hash()
+		if checkTransactionSanity(&ts) == nil {
+			t.Fatalf("\t输出总额超过上限的交易通过了校验")
+		}
+		ts.Vout = []TXOutput{{MaxMoney, []byte("to")}}
+		ts.hash()
+		if err := checkTransactionSanity(&ts); err != nil {
+			t.Fatalf("\t输出金额未超过上限的交易没有通过校验:%s", err)
+		}
+		//奖励交易必须是区块的第一笔交易,且只能有一笔
+		b := newTestTemplate(BigToCompact(ActiveParams.PowLimit))
+		coinbase := b.Transactions[0]
+		other := coinbase.customCopy()
+		other.setExtraNonce(b.Height, []byte{1})
+		for _, tss := range [][]Transaction{{ts, coinbase}, {coinbase, other}} {
+			b.Transactions = tss
+			b.MerkleRoot = calcMerkleRoot(tss)
+			b.Hash = b.CalcHash()
+			if err, ok := (&blockchain{}).checkBlockSanity(b).(*ValidationError); !ok || err.Reason != RejectBadCoinbase {
+				t.Fatalf("\t奖励交易位置或数量不正确的区块通过了校验")
+			}
+		}
+		t.Log("\t金额上限与奖励交易校验正确")
+	}
+}
+
+func TestCoinbaseFees(t *testing.T) {
+	t.Log("测试奖励交易金额为出块奖励加上交易手续费,超过该金额的区块被拒绝")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		a1 := mineTestBlock(t, bc, ActiveParams.GenesisBlock(), miner, nil, 'a')
+		addTestBlock(t, bc, a1)
+		fee := 7
+		ts := newTestPayment(miner, &a1.Transactions[0], 0, payee, fee)
+		b, err := bc.NewBlockTemplate([]Transaction{ts}, string(miner.getAddress()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Transactions[0].Vout[0].Value != GetBlockSubsidy(b.Height)+fee {
+			t.Fatalf("\t奖励交易金额%d不等于出块奖励加手续费", b.Transactions[0].Vout[0].Value)
+		}
+		//多领取1个代币的奖励交易
+		greedy := *b
+		greedy.Transactions = append([]Transaction{}, b.Transactions...)
+		coinbase := greedy.Transactions[0]
+		coinbase.Vout = []TXOutput{{coinbase.Vout[0].Value + 1, coinbase.Vout[0].ScriptPubKey}}
+		coinbase.hash()
+		greedy.Transactions[0] = coinbase
+		greedy.MerkleRoot = calcMerkleRoot(greedy.Transactions)
+		err = mine(context.Background(), &greedy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err, ok := bc.AddBlock(&greedy).(*ValidationError); !ok || err.Reason != RejectBadCoinbase || !strings.Contains(err.Error(), "超过了出块奖励与手续费之和") {
+			t.Fatalf("\t奖励金额超过出块奖励与手续费之和的区块通过了校验")
+		}
+		err = mine(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addTestBlock(t, bc, b)
+		if bc.GetLastBlockHeight() != b.Height {
+			t.Fatalf("\t奖励金额正确的区块没有加入区块链")
+		}
+		t.Log("\t奖励交易金额校验正确")
+	}
+}
@@ -2,13 +2,16 @@ package block
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/util"
 	log "github.com/corgi-kx/logcustom"
 	"math/big"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -24,70 +27,52 @@ func NewBlockchain() *blockchain {
 	return &blockchain
 }
 
-//创建创世区块交易信息
-func (bc *blockchain) CreataGenesisTransaction(address string, value int, send Sender) {
-	//判断地址格式是否正确
-	if !IsVaildBitcoinAddress(address) {
-		log.Errorf("地址格式不正确:%s\n", address)
-		return
+//本地还没有区块时写入当前网络的创世区块(添加区块时会同步写入utxo数据库)
+//本地已有区块时检查本地的创世区块是否属于当前网络,避免不同网络的数据混在一起
+func (bc *blockchain) InitGenesisBlock() error {
+	genesisHash := bc.GetBlockHashByHeight(1)
+	if genesisHash != nil {
+		if hex.EncodeToString(genesisHash) != ActiveParams.GenesisHash {
+			return fmt.Errorf("本地创世区块%x不属于当前网络(%s),请更换数据库或网络类型", genesisHash, ActiveParams.Name)
+		}
+		return nil
 	}
-	//创世区块数据
-	txi := TXInput{[]byte{}, -1, nil, nil}
-	//本地一定要存创世区块地址的公私钥信息
-	wallets := NewWallets(bc.BD)
-	genesisKeys, ok := wallets.Wallets[address]
-	if !ok {
-		log.Fatal("没有找到地址对应的公私钥信息")
-	}
-	//通过地址获得rip160(sha256(publickey))
-	publicKeyHash := generatePublicKeyHash(genesisKeys.PublicKey)
-	txo := TXOutput{value, publicKeyHash}
-	ts := Transaction{nil, []TXInput{txi}, []TXOutput{txo}}
-	ts.hash()
-	tss := []Transaction{ts}
-	//开始生成区块链的第一个区块
-	bc.newGenesisBlockchain(tss)
-	//创世区块后,更新本地最新区块为1并,向全网节点发送当前区块链高度1
-	NewestBlockHeight = 1
-	send.SendVersionToPeers(1)
-	fmt.Println("已成生成创世区块")
-	//重置utxo数据库，将创世数据存入
-	utxos := UTXOHandle{bc}
-	utxos.ResetUTXODataBase()
-}
-
-//创建区块链
-func (bc *blockchain) newGenesisBlockchain(transaction []Transaction) {
-	//判断一下是否已生成创世区块
-	if len(bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)) != 0 {
-		log.Fatal("不可重复生成创世区块")
+	genesisBlock := ActiveParams.GenesisBlock()
+	err := bc.AddBlock(genesisBlock)
+	if err != nil {
+		return err
 	}
-	//生成创世区块
-	genesisBlock := newGenesisBlock(transaction)
-	//添加到数据库中
-	bc.AddBlock(genesisBlock)
+	log.Infof("已写入%s网络的创世区块%x", ActiveParams.Name, genesisBlock.Hash)
+	return nil
 }
 
-//创建挖矿奖励地址交易
-func (bc *blockchain) CreataRewardTransaction(address string) Transaction {
+//创建挖矿奖励地址交易,奖励金额为出块奖励加上区块中交易的手续费
+//交易中包含区块高度,保证每个区块的奖励交易hash不同
+//每个区块都必须有奖励交易,没有有效的奖励地址或已没有奖励时,奖励交易只有一个金额为0的OP_RETURN输出
+func (bc *blockchain) CreataRewardTransaction(address string, height int, fees int) Transaction {
+	reward := GetBlockSubsidy(height) + fees
+	script := []byte{OP_RETURN}
 	if address == "" {
 		log.Warn("没有设置挖矿奖励地址，如果出块则不会给予奖励代币")
-		return Transaction{}
-	}
-	if !IsVaildBitcoinAddress(address) {
+		reward = 0
+	} else if !IsVaildBitcoinAddress(address) {
 		log.Warnf("奖励地址格式不正确:%s\n", address)
-		return Transaction{}
-	}
-
-	publicKeyHash := getPublicKeyHashFromAddress(address)
-	txo := TXOutput{TokenRewardNum, publicKeyHash}
-	ts := Transaction{nil, nil, []TXOutput{txo}}
+		reward = 0
+	} else if reward == 0 {
+		log.Warnf("高度%d的区块已没有挖矿奖励", height)
+	} else {
+		script = NewScriptForAddress(address)
+	}
+	txo := TXOutput{reward, script}
+	ts := Transaction{nil, []TXInput{newCoinbaseInput(height)}, []TXOutput{txo}, 0}
 	ts.hash()
 	return ts
 }
 
-//创建UTXO交易实例
-func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sender) {
+//创建UTXO交易实例,fee为每笔交易支付给矿工的手续费,为空时不支付手续费
+//lockTime为交易的绝对时间锁(小于500000000为区块高度,否则为unix时间戳),为空时不加锁
+//sequence为每个输入的相对时间锁:所花费的输出被打包后至少还需要经过的区块数量,为空时不加锁
+func (bc *blockchain) CreateTransaction(from, to string, amount string, fee string, lockTime string, sequence string, send Sender) {
 	//判断一下是否已生成创世区块
 	if len(bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)) == 0 {
 		log.Error("还没有生成创世区块，不可进行转账操作 !")
@@ -101,6 +86,7 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 	fromSlice := []string{}
 	toSlice := []string{}
 	amountSlice := []int{}
+	feeSlice := []int{}
 
 	//对传入的信息进行校验检测
 	err := json.Unmarshal([]byte(from), &fromSlice)
@@ -118,56 +104,58 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 		log.Error("json err:", err)
 		return
 	}
-	if len(fromSlice) != len(toSlice) || len(fromSlice) != len(amountSlice) {
-		log.Error("转账数组长度不一致")
-		return
+	if fee != "" {
+		err = json.Unmarshal([]byte(fee), &feeSlice)
+		if err != nil {
+			log.Error("json err:", err)
+			return
+		}
+	} else {
+		feeSlice = make([]int, len(fromSlice))
+	}
+	var lockTimeValue int64
+	if lockTime != "" {
+		lockTimeValue, err = strconv.ParseInt(lockTime, 10, 64)
+		if err != nil || lockTimeValue < 0 {
+			log.Errorf("时间锁格式不正确:%s", lockTime)
+			return
+		}
 	}
-
-	for i, v := range fromSlice {
-		if !IsVaildBitcoinAddress(v) {
-			log.Errorf(" %s,地址格式不正确！已将此笔交易剔除\n", v)
-			if i < len(fromSlice)-1 {
-				fromSlice = append(fromSlice[:i], fromSlice[i+1:]...)
-				toSlice = append(toSlice[:i], toSlice[i+1:]...)
-				amountSlice = append(amountSlice[:i], amountSlice[i+1:]...)
-			} else {
-				fromSlice = append(fromSlice[:i])
-				toSlice = append(toSlice[:i])
-				amountSlice = append(amountSlice[:i])
-			}
+	var relativeLock int64
+	if sequence != "" {
+		relativeLock, err = strconv.ParseInt(sequence, 10, 64)
+		if err != nil || relativeLock < 0 || relativeLock >= int64(SequenceReplaceable) {
+			log.Errorf("相对时间锁格式不正确:%s", sequence)
+			return
 		}
 	}
+	if len(fromSlice) != len(toSlice) || len(fromSlice) != len(amountSlice) || len(fromSlice) != len(feeSlice) {
+		log.Error("转账数组长度不一致")
+		return
+	}
 
-	for i, v := range toSlice {
-		if !IsVaildBitcoinAddress(v) {
-			log.Errorf(" %s,地址格式不正确！已将此笔交易剔除\n", v)
-			if i < len(fromSlice)-1 {
-				fromSlice = append(fromSlice[:i], fromSlice[i+1:]...)
-				toSlice = append(toSlice[:i], toSlice[i+1:]...)
-				amountSlice = append(amountSlice[:i], amountSlice[i+1:]...)
-			} else {
-				fromSlice = append(fromSlice[:i])
-				toSlice = append(toSlice[:i])
-				amountSlice = append(amountSlice[:i])
-			}
+	//剔除地址格式不正确、转账金额或手续费小于0的转账
+	validFrom, validTo, validAmount, validFee := []string{}, []string{}, []int{}, []int{}
+	for i := range fromSlice {
+		if !IsVaildBitcoinAddress(fromSlice[i]) || !IsVaildBitcoinAddress(toSlice[i]) {
+			log.Errorf(" %s -> %s,地址格式不正确！已将此笔交易剔除\n", fromSlice[i], toSlice[i])
+			continue
 		}
-	}
-	for i, v := range amountSlice {
-		if v < 0 {
-			log.Error("转账金额不可小于0，已将此笔交易剔除")
-			if i < len(fromSlice)-1 {
-				fromSlice = append(fromSlice[:i], fromSlice[i+1:]...)
-				toSlice = append(toSlice[:i], toSlice[i+1:]...)
-				amountSlice = append(amountSlice[:i], amountSlice[i+1:]...)
-			} else {
-				fromSlice = append(fromSlice[:i])
-				toSlice = append(toSlice[:i])
-				amountSlice = append(amountSlice[:i])
-			}
+		if amountSlice[i] < 0 || feeSlice[i] < 0 {
+			log.Error("转账金额与手续费不可小于0，已将此笔交易剔除")
+			continue
 		}
+		validFrom = append(validFrom, fromSlice[i])
+		validTo = append(validTo, toSlice[i])
+		validAmount = append(validAmount, amountSlice[i])
+		validFee = append(validFee, feeSlice[i])
 	}
+	fromSlice, toSlice, amountSlice, feeSlice = validFrom, validTo, validAmount, validFee
 
 	var tss []Transaction
+	//交易中是否有输入尚未满足相对时间锁
+	sequenceLocked := false
+	nextHeight := bc.GetLastBlockHeight() + 1
 	wallets := NewWallets(bc.BD)
 	for index, fromAddress := range fromSlice {
 		fromKeys, ok := wallets.Wallets[fromAddress]
@@ -175,16 +163,16 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 			log.Errorf("没有找到地址%s所对应的公钥,跳过此笔交易", fromAddress)
 			continue
 		}
-		toKeysPublicKeyHash := getPublicKeyHashFromAddress(toSlice[index])
+		toScript := NewScriptForAddress(toSlice[index])
 		if fromAddress == toSlice[index] {
 			log.Errorf("相同地址不能转账！！！:%s\n", fromAddress)
 			return
 		}
 		u := UTXOHandle{bc}
-		//获取数据库中的未消费的utxo
-		utxos := u.findUTXOFromAddress(fromAddress)
+		//获取数据库中的未消费且可以花费的utxo
+		utxos := u.findSpendableUTXOFromAddress(fromAddress)
 		if len(utxos) == 0 {
-			log.Errorf("%s 余额为0,不能进行转帐操作", fromAddress)
+			log.Errorf("%s 可用余额为0,不能进行转帐操作", fromAddress)
 			return
 		}
 		//将utxos添加上未打包进区块的交易信息
@@ -193,7 +181,7 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 				//先添加未花费utxo 如果有的话就不添加
 			tagVout:
 				for index, vOut := range ts.Vout {
-					if bytes.Compare(vOut.PublicKeyHash, generatePublicKeyHash(fromKeys.PublicKey)) != 0 {
+					if bytes.Compare(vOut.PublicKeyHash(), generatePublicKeyHash(fromKeys.PublicKey)) != 0 {
 						continue
 					}
 					for _, utxo := range utxos {
@@ -201,7 +189,7 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 							continue tagVout
 						}
 					}
-					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut})
+					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut, 0, false})
 				}
 				//剔除已花费的utxo
 				for _, vInt := range ts.Vint {
@@ -215,37 +203,42 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 			}
 		}
 
-		//打包交易的核心操作
+		//打包交易的核心操作,输入金额需要覆盖转账金额与手续费,输入与输出的差额即为手续费
 		newTXInput := []TXInput{}
 		newTXOutput := []TXOutput{}
+		need := amountSlice[index] + feeSlice[index]
 		var amount int
 		for _, utxo := range utxos {
 			amount += utxo.Vout.Value
-			newTXInput = append(newTXInput, TXInput{utxo.Hash, utxo.Index, nil, fromKeys.PublicKey})
-			if amount > amountSlice[index] {
+			//钱包创建的交易都声明可替换,未打包前可以通过bumpFee提高手续费
+			newTXInput = append(newTXInput, TXInput{utxo.Hash, utxo.Index, nil, SequenceReplaceable | uint32(relativeLock)})
+			if nextHeight-utxo.Height < int(relativeLock) {
+				sequenceLocked = true
+			}
+			if amount > need {
 				tfrom := TXOutput{}
-				tfrom.Value = amount - amountSlice[index]
-				tfrom.PublicKeyHash = generatePublicKeyHash(fromKeys.PublicKey)
+				tfrom.Value = amount - need
+				tfrom.ScriptPubKey = NewP2PKHScript(generatePublicKeyHash(fromKeys.PublicKey))
 				tTo := TXOutput{}
 				tTo.Value = amountSlice[index]
-				tTo.PublicKeyHash = toKeysPublicKeyHash
+				tTo.ScriptPubKey = toScript
 				newTXOutput = append(newTXOutput, tfrom)
 				newTXOutput = append(newTXOutput, tTo)
 				break
-			} else if amount == amountSlice[index] {
+			} else if amount == need {
 				tTo := TXOutput{}
 				tTo.Value = amountSlice[index]
-				tTo.PublicKeyHash = toKeysPublicKeyHash
+				tTo.ScriptPubKey = toScript
 				newTXOutput = append(newTXOutput, tTo)
 				break
 			}
 		}
 		//如果余额不足则跳过不会打包进入交易
-		if amount < amountSlice[index] {
+		if amount < need {
 			log.Errorf(" 第%d笔交易%s余额不足", index+1, fromAddress)
 			continue
 		}
-		ts := Transaction{nil, newTXInput, newTXOutput[:]}
+		ts := Transaction{nil, newTXInput, newTXOutput[:], lockTimeValue}
 		ts.hash()
 		tss = append(tss, ts)
 	}
@@ -253,44 +246,58 @@ func (bc *blockchain) CreateTransaction(from, to string, amount string, send Sen
 		return
 	}
 	bc.signatureTransactions(tss, wallets)
+	//尚未到达时间锁的交易不会被其他节点接收,打印出来待到期后再广播
+	if sequenceLocked || lockTimeValue != 0 && !tss[0].IsFinal(nextHeight, bc.tipPastMedianTime()) {
+		for _, ts := range tss {
+			fmt.Printf("交易%x尚未到达时间锁(LockTime %d,相对时间锁%d个区块),请到期后使用sendRawTx命令广播:\n%s\n", ts.TxHash, ts.LockTime, relativeLock, hex.EncodeToString(ts.Serialize()))
+		}
+		return
+	}
 	//向P2P节点发送交易数据
 	send.SendTransToPeers(tss)
 }
 
 //交易转账
 func (bc *blockchain) Transfer(tss []Transaction, send Sender) {
-	//如果是创世区块的交易则无需进行数字签名验证
-	if !isGenesisTransaction(tss) {
-		//交易的数字签名验证
-		bc.verifyTransactionsSign(&tss)
-		if len(tss) == 0 {
-			log.Error("没有通过的数字签名验证，不予挖矿出块！")
-			return
+	//重新计算交易hash,剔除交易hash与内容不一致的交易,奖励交易只能由出块节点自己生成
+	for i := 0; i < len(tss); i++ {
+		if tss[i].IsCoinbase() || !tss[i].VerifyTxHash() {
+			log.Errorf("交易%x的交易hash与交易内容不一致或为奖励交易,已将此笔交易剔除", tss[i].TxHash)
+			tss = append(tss[:i], tss[i+1:]...)
+			i--
 		}
 	}
+	//交易的数字签名验证
+	bc.verifyTransactionsSign(&tss)
 	//进行余额验证
 	bc.VerifyTransBalance(&tss)
 	if len(tss) == 0 {
-		log.Error("没有通过余额验证的交易，不予挖矿出块！")
-		return
-	}
-	//如果设置了奖励地址，则挖矿成功后给予奖励代币
-	rewardTs := bc.CreataRewardTransaction(string(bc.BD.View([]byte(RewardAddrMapping), database.AddrBucket)))
-	if rewardTs.TxHash != nil {
-		tss = append(tss, rewardTs)
+		log.Debug("没有可以打包的交易,将出空块")
 	}
 	bc.addBlockchain(tss, send)
 }
 
 //校验交易余额是否足够,如果不够则剔除
 func (bc *blockchain) VerifyTransBalance(tss *[]Transaction) {
+	//剔除花费了尚未成熟的奖励输出的交易
+	spendHeight := bc.GetLastBlockHeight() + 1
+	for i := 0; i < len(*tss); i++ {
+		if bc.spendsImmatureCoinbase(&(*tss)[i], spendHeight) {
+			log.Errorf("交易%x花费了尚未成熟的奖励输出(需经过%d个区块)，已将此笔交易剔除！", (*tss)[i].TxHash, ActiveParams.CoinbaseMaturity)
+			*tss = append((*tss)[:i], (*tss)[i+1:]...)
+			i--
+		}
+	}
 	//获取每个地址的UTXO余额，并存入字典中
 	var balance = map[string]int{}
 	for i := range *tss {
-		fromAddress := GetAddressFromPublicKey((*tss)[i].Vint[0].PublicKey)
-		//获取数据库中的utxo
+		fromAddress := bc.getFromAddress(*tss, &(*tss)[i])
+		if fromAddress == "" {
+			continue
+		}
+		//获取数据库中可以花费的utxo
 		u := UTXOHandle{bc}
-		utxos := u.findUTXOFromAddress(fromAddress)
+		utxos := u.findSpendableUTXOFromAddress(fromAddress)
 		if len(utxos) == 0 {
 			log.Warnf("%s 余额为0！", fromAddress)
 			continue
@@ -304,9 +311,13 @@ func (bc *blockchain) VerifyTransBalance(tss *[]Transaction) {
 
 circle:
 	for i := range *tss {
-		fromAddress := GetAddressFromPublicKey((*tss)[i].Vint[0].PublicKey)
+		fromAddress := bc.getFromAddress(*tss, &(*tss)[i])
+		//非标准脚本的输出没有地址,只由交易输入校验保证余额
+		if fromAddress == "" {
+			continue
+		}
 		u := UTXOHandle{bc}
-		utxos := u.findUTXOFromAddress(fromAddress)
+		utxos := u.findSpendableUTXOFromAddress(fromAddress)
 		var utxoAmount int //vint将要花费的总utxo
 		var voutAmount int //vout剩余的utxo
 		var costAmount int //vint将要花费的总utxo减去vout剩余的utxo等于花费的钱数
@@ -319,7 +330,7 @@ circle:
 			}
 		}
 		for _, vOut := range (*tss)[i].Vout {
-			if bytes.Equal(getPublicKeyHashFromAddress(fromAddress), vOut.PublicKeyHash) {
+			if vOut.Address() == fromAddress {
 				voutAmount += vOut.Value
 			}
 		}
@@ -341,91 +352,117 @@ circle:
 	log.Debug("已完成UTXO交易余额验证")
 }
 
+//获取交易第一个输入所花费输出的地址(普通地址或多重签名地址)
+func (bc *blockchain) getFromAddress(tss []Transaction, ts *Transaction) string {
+	vIn := ts.Vint[0]
+	trans, err := bc.findTransaction(tss, vIn.TxHash)
+	if err != nil || vIn.Index < 0 || vIn.Index >= len(trans.Vout) {
+		return ""
+	}
+	return trans.Vout[vIn.Index].Address()
+}
+
+//依次校验交易的输入并统计手续费(输入总金额减去输出总金额),没有通过校验的交易会被剔除
+func (bc *blockchain) collectFees(tss *[]Transaction, height int) int {
+	view := newUTXOView(bc, height)
+	fees := 0
+	for i := 0; i < len(*tss); i++ {
+		fee, err := bc.checkTransactionInputs(&(*tss)[i], view)
+		if err != nil {
+			log.Errorf("交易%x没有通过输入校验，已将此笔交易剔除：%s", (*tss)[i].TxHash, err)
+			*tss = append((*tss)[:i], (*tss)[i+1:]...)
+			i--
+			continue
+		}
+		view.addTransaction(&(*tss)[i])
+		fees += fee
+	}
+	log.Debugf("本块交易手续费共计%d", fees)
+	return fees
+}
+
 //设置挖矿奖励地址
 func (bc *blockchain) SetRewardAddress(address string) {
 	bc.BD.Put([]byte(RewardAddrMapping), []byte(address), database.AddrBucket)
 }
 
+//获取挖矿奖励地址,没有设置时返回空字符串
+func (bc *blockchain) GetRewardAddress() string {
+	return string(bc.BD.View([]byte(RewardAddrMapping), database.AddrBucket))
+}
+
 //将交易添加进区块链中(内含挖矿操作)
 func (bc *blockchain) addBlockchain(transaction []Transaction, send Sender) {
-	preBlockbyte := bc.BD.View(bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket), database.BlockBucket)
-	preBlock := Block{}
-	preBlock.Deserialize(preBlockbyte)
-	height := preBlock.Height + 1
-	//进行挖矿
-	nb, err := mineBlock(transaction, bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket), height)
+	//在读取最新区块之前获取挖矿上下文,挖矿期间最新区块发生变化时终止挖矿
+	ctx := miningContext()
+	height := bc.GetLastBlockHeight() + 1
+	//poa共识下只有轮到出块的验证者才能出块
+	producer := Engine.NextProducer(bc, height)
+	if producer != "" {
+		if _, ok := NewWallets(bc.BD).Wallets[producer]; !ok {
+			log.Infof("高度%d应由验证者%s出块,本节点不出块", height, producer)
+			return
+		}
+	}
+	//如果设置了奖励地址，则挖矿成功后给予奖励代币与手续费
+	nb, err := bc.NewBlockTemplate(transaction, bc.GetRewardAddress())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	//进行挖矿(poa共识下为签名出块)
+	err = sealBlock(ctx, bc, nb)
 	if err != nil {
 		log.Warn(err)
 		return
 	}
-	//将区块添加到本地库中
-	bc.AddBlock(nb)
-	//将数据同步到UTXO数据库中
-	u := UTXOHandle{bc}
-	u.Synchrodata(transaction)
+	//将区块添加到本地库中,与网络中接收到的区块经过同样的校验,同时会将数据同步到UTXO数据库中
+	err = bc.AddBlock(nb)
+	if err != nil {
+		log.Errorf("本节点挖出的区块%x没有通过校验:%s", nb.Hash, err)
+		return
+	}
 	//挖矿出块后 发送高度信息到其他节点
 	send.SendVersionToPeers(nb.Height)
 }
 
-//添加区块信息到数据库，并更新lastHash
-func (bc *blockchain) AddBlock(block *Block) {
-	bc.BD.Put(block.Hash, block.Serialize(), database.BlockBucket)
-	bci := NewBlockchainIterator(bc)
-	currentBlock := bci.Next()
-	if currentBlock == nil || currentBlock.Height < block.Height {
-		bc.BD.Put([]byte(LastBlockHashMapping), block.Hash, database.BlockBucket)
-	}
-}
-
-//对交易信息进行数字签名
+//对交易信息进行数字签名,生成花费P2PKH输出的解锁脚本
 func (bc *blockchain) signatureTransactions(tss []Transaction, wallets *wallets) {
 	for i := range tss {
-		copyTs := tss[i].customCopy()
 		for index := range tss[i].Vint {
-			//获取地址
-			bk := bitcoinKeys{nil, tss[i].Vint[index].PublicKey, nil}
-			address := bk.getAddress()
 			//从数据库或者为打包进数据库的交易数组中,找到vint所对应的交易信息
 			trans, err := bc.findTransaction(tss, tss[i].Vint[index].TxHash)
 			if err != nil {
 				log.Fatal(err)
 			}
-			copyTs.Vint[index].Signature = nil
-			//将拷贝后的交易里面的公钥替换为公钥hash
-			copyTs.Vint[index].PublicKey = trans.Vout[tss[i].Vint[index].Index].PublicKeyHash
-			//对拷贝后的交易进行整体hash
-			copyTs.TxHash = copyTs.hashSign()
-			copyTs.Vint[index].PublicKey = nil
-			privKey := wallets.Wallets[string(address)].PrivateKey
+			prevOut := trans.Vout[tss[i].Vint[index].Index]
+			//通过所花费输出的公钥hash找到对应的公私钥
+			address := GetAddressFromPublicKeyHash(prevOut.PublicKeyHash())
+			keys, ok := wallets.Wallets[address]
+			if !ok {
+				log.Fatalf("没有找到地址%s所对应的私钥,无法签名", address)
+			}
 			//进行签名操作
-			tss[i].Vint[index].Signature = ellipticCurveSign(privKey, copyTs.TxHash)
+			signature := ellipticCurveSign(keys.PrivateKey, tss[i].signatureHash(index, prevOut.ScriptPubKey))
+			tss[i].Vint[index].ScriptSig = newP2PKHSigScript(signature, keys.PublicKey)
 		}
 	}
 }
 
-//数字签名验证
+//数字签名验证,签名hash中包含当前网络的链id,其他网络签名的交易无法通过验证
 func (bc *blockchain) verifyTransactionsSign(tss *[]Transaction) {
 circle:
 	for i := range *tss {
-		copyTs := (*tss)[i].customCopy()
 		for index, Vin := range (*tss)[i].Vint {
 			findTs, err := bc.findTransaction(*tss, Vin.TxHash)
-			if err != nil {
-				log.Fatal(err)
-			}
-			//先验证输入地址的公钥hash与指定的utxo输出的公钥hash是否相同
-			if !bytes.Equal(findTs.Vout[Vin.Index].PublicKeyHash, generatePublicKeyHash(Vin.PublicKey)) {
-				log.Errorf("签名验证失败 %x笔交易的vin并非是本人", (*tss)[i].TxHash)
+			if err != nil || Vin.Index < 0 || Vin.Index >= len(findTs.Vout) {
+				log.Errorf("此笔交易：%x引用的输出不存在，已将此笔交易剔除", (*tss)[i].TxHash)
 				*tss = append((*tss)[:i], (*tss)[i+1:]...)
 				goto circle
 			}
-			copyTs.Vint[index].Signature = nil
-			copyTs.Vint[index].PublicKey = findTs.Vout[Vin.Index].PublicKeyHash
-			copyTs.TxHash = copyTs.hashSign()
-			copyTs.Vint[index].PublicKey = nil
-			//进行签名验证
-			if !ellipticCurveVerify(Vin.PublicKey, Vin.Signature, copyTs.TxHash) {
-				log.Errorf("此笔交易：%x没通过签名验证", (*tss)[i].TxHash)
+			//执行解锁脚本与锁定脚本,进行签名验证
+			if err := (*tss)[i].verifyInputScript(index, findTs.Vout[Vin.Index]); err != nil {
+				log.Errorf("此笔交易：%x没通过签名验证:%s", (*tss)[i].TxHash, err)
 				*tss = append((*tss)[:i], (*tss)[i+1:]...)
 				goto circle
 			}
@@ -448,7 +485,9 @@ func (bc *blockchain) findTransaction(tss []Transaction, ID []byte) (Transaction
 	//在查找数据库中存在的交易
 	for {
 		block := bci.Next()
-
+		if block == nil {
+			break
+		}
 		for _, tx := range block.Transactions {
 			if bytes.Compare(tx.TxHash, ID) == 0 {
 				return tx, nil
@@ -474,19 +513,27 @@ func (bc *blockchain) GetLastBlockHeight() int {
 	return lastblock.Height
 }
 
-//通过高度获取区块hash
-func (bc *blockchain) GetBlockHashByHeight(height int) []byte {
+//获取最新区块的时间戳,还没有区块时返回0
+func (bc *blockchain) GetLastBlockTime() int64 {
 	bcl := NewBlockchainIterator(bc)
-	for {
-		currentBlock := bcl.Next()
-		if currentBlock == nil {
-			return nil
-		} else if currentBlock.Height == height {
-			return currentBlock.Hash
-		} else if isGenesisBlock(currentBlock) {
-			return nil
-		}
+	lastblock := bcl.Next()
+	if lastblock == nil {
+		return 0
 	}
+	return lastblock.TimeStamp
+}
+
+//通过高度获取主链上的区块hash,没有该高度的区块时返回nil
+func (bc *blockchain) GetBlockHashByHeight(height int) []byte {
+	hash := bc.BD.View(heightKey(height), database.HeightBucket)
+	if len(hash) == 0 {
+		return nil
+	}
+	return hash
+}
+
+func heightKey(height int) []byte {
+	return util.Int64ToBytes(int64(height))
 }
 
 //通过区块hash获取区块信息
@@ -509,6 +556,21 @@ func (bc *blockchain) GetBalance(address string) int {
 	return balance
 }
 
+//传入地址 返回地址可以花费的余额(不含尚未成熟的奖励)
+func (bc *blockchain) GetSpendableBalance(address string) int {
+	if !IsVaildBitcoinAddress(address) {
+		log.Errorf("地址格式不正确：%s\n", address)
+		return 0
+	}
+	var balance int
+	uHandle := UTXOHandle{bc}
+	utxos := uHandle.findSpendableUTXOFromAddress(address)
+	for _, v := range utxos {
+		balance += v.Vout.Value
+	}
+	return balance
+}
+
 //查找数据库中全部未花费的UTXO
 func (bc *blockchain) findAllUTXOs() map[string][]*UTXO {
 	utxosMap := make(map[string][]*UTXO)
@@ -530,14 +592,14 @@ func (bc *blockchain) findAllUTXOs() map[string][]*UTXO {
 		VoutTag:
 			for index, vOut := range ts.Vout {
 				if txInputmap[string(ts.TxHash)] == nil {
-					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut})
+					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut, currentBlock.Height, ts.IsCoinbase()})
 				} else {
 					for _, vIn := range txInputmap[string(ts.TxHash)] {
 						if vIn.Index == index {
 							continue VoutTag
 						}
 					}
-					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut})
+					utxos = append(utxos, &UTXO{ts.TxHash, index, vOut, currentBlock.Height, ts.IsCoinbase()})
 				}
 				utxosMap[string(ts.TxHash)] = utxos
 			}
@@ -564,19 +626,35 @@ func (bc *blockchain) PrintAllBlockInfo() {
 		fmt.Println("  	------------------------------交易数据------------------------------")
 		for _, v := range block.Transactions {
 			fmt.Printf("   	 本次交易id:  %x\n", v.TxHash)
+			if v.LockTime != 0 {
+				fmt.Printf("   	 时间锁:  %d\n", v.LockTime)
+			}
 			fmt.Println("   	  tx_input：")
 			for _, vIn := range v.Vint {
 				fmt.Printf("			交易id:  %x\n", vIn.TxHash)
 				fmt.Printf("			索引:    %d\n", vIn.Index)
-				fmt.Printf("			签名信息:    %x\n", vIn.Signature)
-				fmt.Printf("			公钥:    %x\n", vIn.PublicKey)
-				fmt.Printf("			地址:    %s\n", GetAddressFromPublicKey(vIn.PublicKey))
+				if vIn.Index == -1 {
+					fmt.Printf("			区块高度与额外随机数:    %x\n", vIn.ScriptSig)
+					continue
+				}
+				fmt.Printf("			解锁脚本:    %s\n", DisasmScript(vIn.ScriptSig))
+				if vIn.RelativeLock() != 0 {
+					fmt.Printf("			相对时间锁:    %d个区块\n", vIn.RelativeLock())
+				}
+				if vIn.Sequence&SequenceReplaceable != 0 {
+					fmt.Println("			可替换(RBF):    是")
+				}
+				if publicKey := vIn.PublicKey(); publicKey != nil {
+					fmt.Printf("			地址:    %s\n", GetAddressFromPublicKey(publicKey))
+				}
 			}
 			fmt.Println("  	  tx_output：")
 			for index, vOut := range v.Vout {
 				fmt.Printf("			金额:    %d    \n", vOut.Value)
-				fmt.Printf("			公钥Hash:    %x    \n", vOut.PublicKeyHash)
-				fmt.Printf("			地址:    %s\n", GetAddressFromPublicKeyHash(vOut.PublicKeyHash))
+				fmt.Printf("			锁定脚本:    %s\n", DisasmScript(vOut.ScriptPubKey))
+				if address := vOut.Address(); address != "" {
+					fmt.Printf("			地址:    %s\n", address)
+				}
 				if len(v.Vout) != 1 && index != len(v.Vout)-1 {
 					fmt.Println("			---------------")
 				}
@@ -586,6 +664,11 @@ func (bc *blockchain) PrintAllBlockInfo() {
 		fmt.Printf("时间戳           %s\n", time.Unix(block.TimeStamp, 0).Format("2006-01-02 03:04:05 PM"))
 		fmt.Printf("区块高度         %d\n", block.Height)
 		fmt.Printf("随机数           %d\n", block.Nonce)
+		fmt.Printf("难度值           %08x\n", block.Bits)
+		if len(block.Signer) != 0 {
+			fmt.Printf("出块者           %s\n", GetAddressFromPublicKey(block.Signer))
+		}
+		fmt.Printf("默克尔根         %x\n", block.MerkleRoot)
 		fmt.Printf("上一个块hash     %x\n", block.PreHash)
 		var hashInt big.Int
 		hashInt.SetBytes(block.PreHash)
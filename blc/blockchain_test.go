@@ -0,0 +1,130 @@
+package block
+
+import (
+	"context"
+	"github.com/boltdb/bolt"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"os"
+	"sort"
+	"testing"
+)
+
+//测试中不向其他节点发送消息
+type testSender struct{}
+
+func (s testSender) SendVersionToPeers(height int)      {}
+func (s testSender) SendTransToPeers(tss []Transaction) {}
+
+//在临时目录中创建一条只有创世区块的回归测试网区块链,测试结束后恢复原来的设置
+func newTestChain(t *testing.T) *blockchain {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, params, engine := ListenPort, ActiveParams, Engine
+	ListenPort, database.ListenPort = "test", "test"
+	ActiveParams, Engine = &RegTestParams, &powEngine{}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		ListenPort, database.ListenPort = port, port
+		ActiveParams, Engine = params, engine
+	})
+	bc := NewBlockchain()
+	err = bc.InitGenesisBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc
+}
+
+//在parent之后挖一个区块,奖励交易付给keys(为nil时不给予奖励),
+//tag写入奖励交易的额外随机数,区分不同分支上相同高度的区块
+func mineTestBlock(t *testing.T, bc *blockchain, parent *Block, keys *bitcoinKeys, tss []Transaction, tag byte) *Block {
+	height := parent.Height + 1
+	vOut := TXOutput{0, []byte{OP_RETURN}}
+	if keys != nil {
+		vOut = TXOutput{GetBlockSubsidy(height), NewP2PKHScript(generatePublicKeyHash(keys.PublicKey))}
+	}
+	coinbase := Transaction{nil, []TXInput{newCoinbaseInput(height)}, []TXOutput{vOut}, 0}
+	coinbase.setExtraNonce(height, []byte{tag})
+	tss = append([]Transaction{coinbase}, tss...)
+	header := BlockHeader{blockVersion, parent.Hash, calcMerkleRoot(tss), parent.TimeStamp + 1, 0, 0, height, nil, nil}
+	b := &Block{header, tss, nil}
+	err := Engine.Prepare(bc, &b.BlockHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mine(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+//创建一笔由keys签名、花费prev第index个输出的交易,扣除手续费fee后全部转给to
+func newTestPayment(keys *bitcoinKeys, prev *Transaction, index int, to *bitcoinKeys, fee int) Transaction {
+	ts := Transaction{nil, []TXInput{{prev.TxHash, index, nil, 0}}, []TXOutput{{prev.Vout[index].Value - fee, NewP2PKHScript(generatePublicKeyHash(to.PublicKey))}}, 0}
+	ts.hash()
+	signature := ellipticCurveSign(keys.PrivateKey, ts.signatureHash(0, prev.Vout[index].ScriptPubKey))
+	ts.Vint[0].ScriptSig = newP2PKHSigScript(signature, keys.PublicKey)
+	return ts
+}
+
+//读取仓库中的全部数据
+func dumpTestBucket(t *testing.T, bt database.BucketType) map[string][]byte {
+	db, err := bolt.Open("blockchain_"+ListenPort+".db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	result := map[string][]byte{}
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bt))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			result[string(k)] = append([]byte{}, v...)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+//读取utxo数据库,按输出索引排序并去掉已全部花费的交易,便于比较两个utxo集合是否相同
+func dumpTestUTXOs(t *testing.T, bc *blockchain) map[string][]*UTXO {
+	u := UTXOHandle{bc}
+	result := map[string][]*UTXO{}
+	for k, v := range dumpTestBucket(t, database.UTXOBucket) {
+		utxos := u.dserialize(v)
+		if len(utxos) == 0 {
+			continue
+		}
+		sort.Slice(utxos, func(i, j int) bool { return utxos[i].Index < utxos[j].Index })
+		result[k] = utxos
+	}
+	return result
+}
+
+func TestTransferEmptyBlock(t *testing.T) {
+	t.Log("测试交易池为空时出块节点出一个只有奖励交易的空块")
+	{
+		bc := newTestChain(t)
+		bc.Transfer(nil, testSender{})
+		if bc.GetLastBlockHeight() != 2 {
+			t.Fatalf("\t没有交易时没有出空块,当前高度为%d", bc.GetLastBlockHeight())
+		}
+		b := bc.getBlock(bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket))
+		if len(b.Transactions) != 1 || !b.Transactions[0].IsCoinbase() {
+			t.Fatalf("\t空块中应只有奖励交易")
+		}
+		t.Log("\t已出空块")
+	}
+}
@@ -0,0 +1,74 @@
+/*
+	提高手续费(RBF):钱包创建的交易都声明可替换,交易尚未打包时,
+	可以花费相同的输入、从找零中扣除新增的手续费,重新签名生成一笔手续费更高的替换交易
+*/
+package block
+
+import (
+	"errors"
+	"fmt"
+)
+
+//为交易池中尚未打包的交易ts生成替换交易,fee为替换交易的总手续费,必须大于原交易的手续费
+//只支持花费已打包输出、且输入都属于本地钱包的交易,新增的手续费从找零输出中扣除
+func (bc *blockchain) BumpFee(ts Transaction, fee int) (*Transaction, error) {
+	if ts.IsCoinbase() || len(ts.Vint) == 0 {
+		return nil, errors.New("奖励交易不能提高手续费")
+	}
+	if !ts.IsReplaceable() {
+		return nil, fmt.Errorf("交易%x没有声明可替换,不能提高手续费", ts.TxHash)
+	}
+	wallets := NewWallets(bc.BD)
+	inAmount := 0
+	from := ""
+	for _, vIn := range ts.Vint {
+		trans, err := bc.findTransaction(nil, vIn.TxHash)
+		if err != nil || vIn.Index < 0 || vIn.Index >= len(trans.Vout) {
+			return nil, fmt.Errorf("交易%x花费的输出%x:%d尚未被打包,不能提高手续费", ts.TxHash, vIn.TxHash, vIn.Index)
+		}
+		prevOut := trans.Vout[vIn.Index]
+		if prevOut.PublicKeyHash() == nil {
+			return nil, fmt.Errorf("输出%x:%d不是普通地址的输出", vIn.TxHash, vIn.Index)
+		}
+		address := GetAddressFromPublicKeyHash(prevOut.PublicKeyHash())
+		if _, ok := wallets.Wallets[address]; !ok {
+			return nil, fmt.Errorf("本地钱包中没有地址%s所对应的私钥", address)
+		}
+		if from == "" {
+			from = address
+		}
+		inAmount += prevOut.Value
+	}
+	outAmount := 0
+	for _, vOut := range ts.Vout {
+		outAmount += vOut.Value
+	}
+	oldFee := inAmount - outAmount
+	if fee <= oldFee {
+		return nil, fmt.Errorf("新的手续费%d必须大于原交易的手续费%d", fee, oldFee)
+	}
+	//找零输出为付给第一个输入所属地址的输出
+	newTs := ts.customCopy()
+	change := -1
+	for index, vOut := range newTs.Vout {
+		if vOut.Address() == from {
+			change = index
+			break
+		}
+	}
+	if change == -1 || newTs.Vout[change].Value < fee-oldFee {
+		return nil, fmt.Errorf("交易%x的找零不足以支付新增的手续费%d", ts.TxHash, fee-oldFee)
+	}
+	newTs.Vout[change].Value -= fee - oldFee
+	//找零为0时去掉找零输出
+	if newTs.Vout[change].Value == 0 {
+		newTs.Vout = append(newTs.Vout[:change], newTs.Vout[change+1:]...)
+	}
+	if len(newTs.Vout) == 0 {
+		return nil, errors.New("替换交易没有输出")
+	}
+	newTs.hash()
+	tss := []Transaction{newTs}
+	bc.signatureTransactions(tss, wallets)
+	return &tss[0], nil
+}
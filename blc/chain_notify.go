@@ -0,0 +1,26 @@
+package block
+
+//区块接入或断开主链时的通知,用于交易池等模块同步主链的变化
+type ChainListener interface {
+	BlockConnected(block *Block)
+	BlockDisconnected(block *Block)
+}
+
+var chainListeners []ChainListener
+
+//注册主链变化的监听者
+func RegisterChainListener(l ChainListener) {
+	chainListeners = append(chainListeners, l)
+}
+
+func notifyBlockConnected(block *Block) {
+	for _, l := range chainListeners {
+		l.BlockConnected(block)
+	}
+}
+
+func notifyBlockDisconnected(block *Block) {
+	for _, l := range chainListeners {
+		l.BlockDisconnected(block)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+	网络参数:主网(main)、测试网(test)与回归测试网(regtest)各自拥有固定的创世区块、网络魔数、地址版本号、
+	链id、出块奖励规则与难度规则,通过配置文件选择网络后这些参数会一起切换,不同网络的节点与数据互不兼容
+*/
+package block
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+type ChainParams struct {
+	//网络名称
+	Name string
+	//链id,参与交易签名hash的计算,并在节点握手时校验,其他网络签名的交易与其他网络的节点都会被拒绝
+	ChainID uint32
+	//网络魔数,每条网络消息都以它开头,节点不处理其他网络的消息
+	NetMagic [4]byte
+	//节点组唯一标识名称(mdns发现节点时使用)
+	RendezvousString string
+	//网络传输流的协议id
+	ProtocolID string
+	//普通地址的版本号
+	PubKeyHashAddrID byte
+	//多重签名(P2SH)地址的版本号
+	ScriptHashAddrID byte
+	//挖矿奖励代币数量(减半前)
+	TokenRewardNum int
+	//奖励减半周期,每隔多少个区块奖励减半,为0时不减半
+	HalvingInterval int
+	//挖矿奖励代币的总量上限,为0时只受减半规则限制
+	MaxTokenSupply int
+	//奖励交易的输出需要经过多少个区块后才能花费
+	CoinbaseMaturity int
+	//难度最低时目标值的上限
+	PowLimit *big.Int
+	//难度调整周期,每隔多少个区块调整一次难度,小于等于1时不调整
+	RetargetInterval int
+	//期望的出块间隔(秒)
+	TargetBlockTime int64
+	//创世区块的时间戳
+	GenesisTimeStamp int64
+	//创世区块的随机数
+	GenesisNonce int64
+	//创世区块奖励交易中OP_RETURN输出记录的信息
+	GenesisMessage string
+	//创世区块hash(十六进制)
+	GenesisHash string
+}
+
+//主网参数
+var MainNetParams = ChainParams{
+	Name:             "main",
+	ChainID:          1,
+	NetMagic:         [4]byte{0xd4, 0x3a, 0x6e, 0xc1},
+	RendezvousString: "meetme",
+	ProtocolID:       "/chain/1.1.0",
+	PubKeyHashAddrID: 0x00,
+	ScriptHashAddrID: 0x05,
+	TokenRewardNum:   25,
+	HalvingInterval:  1000,
+	MaxTokenSupply:   40000,
+	CoinbaseMaturity: 10,
+	//至少需要8位前导0,创世区块使用最低难度
+	PowLimit:         newPowLimit(8),
+	RetargetInterval: 10,
+	TargetBlockTime:  30,
+	GenesisTimeStamp: 1577836800,
+	GenesisNonce:     12,
+	GenesisMessage:   "blockchain_golang mainnet genesis block",
+	GenesisHash:      "006e0f7e2e5239139e5555eeb5281502ec13a1232bd3930ea1593fb361b5636b",
+}
+
+//测试网参数:地址版本号与比特币测试网一致
+var TestNetParams = ChainParams{
+	Name:             "test",
+	ChainID:          2,
+	NetMagic:         [4]byte{0x5e, 0x92, 0x1b, 0x7a},
+	RendezvousString: "meetme-testnet",
+	ProtocolID:       "/chain-testnet/1.1.0",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	TokenRewardNum:   25,
+	HalvingInterval:  1000,
+	MaxTokenSupply:   40000,
+	CoinbaseMaturity: 10,
+	PowLimit:         newPowLimit(8),
+	RetargetInterval: 10,
+	TargetBlockTime:  30,
+	GenesisTimeStamp: 1577836800,
+	GenesisNonce:     87,
+	GenesisMessage:   "blockchain_golang testnet genesis block",
+	GenesisHash:      "0071b4d05545fa828ef426ea1dc67582a143acf7dedc2024720e43c98e827c93",
+}
+
+//回归测试网参数:难度固定为最低,奖励很快成熟,用于本地开发与测试
+var RegTestParams = ChainParams{
+	Name:             "regtest",
+	ChainID:          3,
+	NetMagic:         [4]byte{0xa7, 0xc5, 0x3f, 0x10},
+	RendezvousString: "meetme-regtest",
+	ProtocolID:       "/chain-regtest/1.1.0",
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	TokenRewardNum:   50,
+	HalvingInterval:  150,
+	MaxTokenSupply:   0,
+	CoinbaseMaturity: 1,
+	PowLimit:         newPowLimit(1),
+	RetargetInterval: 0,
+	TargetBlockTime:  30,
+	GenesisTimeStamp: 1577836800,
+	GenesisNonce:     0,
+	GenesisMessage:   "blockchain_golang regtest genesis block",
+	GenesisHash:      "1646ee22ddc3beb3162eda120d4bb1bd72aa81885d26cc31f9dc721df0c2b264",
+}
+
+//当前使用的网络参数,默认为主网
+var ActiveParams = &MainNetParams
+
+//根据网络名称设置当前使用的网络参数
+func SetChainParams(name string) error {
+	switch name {
+	case "", "main":
+		ActiveParams = &MainNetParams
+	case "test":
+		ActiveParams = &TestNetParams
+	case "regtest":
+		ActiveParams = &RegTestParams
+	default:
+		return fmt.Errorf("SetChainParams err : 不支持的网络类型%s", name)
+	}
+	return nil
+}
+
+//至少需要zeroBits位前导0的目标值上限
+func newPowLimit(zeroBits uint) *big.Int {
+	return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256-zeroBits), big.NewInt(1))
+}
+
+//创世区块的难度值(compact格式),由难度最低时的目标值上限得出
+func (p *ChainParams) GenesisBits() uint32 {
+	return BigToCompact(p.PowLimit)
+}
+
+//根据网络参数生成创世区块,区块中的所有字段都是固定的,同一网络的节点生成的创世区块完全一致
+//创世区块的奖励交易只有一个记录创世信息的OP_RETURN输出,不分配任何代币
+func (p *ChainParams) GenesisBlock() *Block {
+	script := append([]byte{OP_RETURN}, pushData([]byte(p.GenesisMessage))...)
+	ts := Transaction{nil, []TXInput{newCoinbaseInput(1)}, []TXOutput{{0, script}}, 0}
+	ts.hash()
+	tss := []Transaction{ts}
+	//创世区块的上一个块hash为32个字节的0
+	header := BlockHeader{blockVersion, make([]byte, 32), calcMerkleRoot(tss), p.GenesisTimeStamp, p.GenesisBits(), p.GenesisNonce, 1, nil, nil}
+	block := &Block{header, tss, nil}
+	block.Hash = block.CalcHash()
+	return block
+}
+
+//判断区块头是否为当前网络的创世区块头
+func isActiveGenesis(header *BlockHeader) bool {
+	return hex.EncodeToString(header.CalcHash()) == ActiveParams.GenesisHash
+}
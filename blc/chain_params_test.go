@@ -0,0 +1,42 @@
+package block
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenesisBlock(t *testing.T) {
+	t.Log("测试各网络的创世区块与网络参数一致")
+	{
+		defer SetChainParams("main")
+		for _, name := range []string{"main", "test", "regtest"} {
+			if err := SetChainParams(name); err != nil {
+				t.Fatalf("\t%s", err)
+			}
+			b := ActiveParams.GenesisBlock()
+			if hex.EncodeToString(b.Hash) != ActiveParams.GenesisHash {
+				t.Fatalf("\t%s网络的创世区块hash不正确:%x", name, b.Hash)
+			}
+			if !NewProofOfWork(&b.BlockHeader).checkHash() {
+				t.Fatalf("\t%s网络的创世区块不满足工作量证明", name)
+			}
+			bc := &blockchain{}
+			if err := bc.checkBlockSanity(b); err != nil {
+				t.Fatalf("\t%s网络的创世区块没有通过校验:%s", name, err)
+			}
+			if err := bc.checkHeader(&b.BlockHeader); err != nil {
+				t.Fatalf("\t%s网络的创世区块头没有通过校验:%s", name, err)
+			}
+		}
+		//其他网络的创世区块不能通过校验
+		SetChainParams("main")
+		b := TestNetParams.GenesisBlock()
+		if err := (&blockchain{}).checkHeader(&b.BlockHeader); err == nil {
+			t.Fatalf("\t测试网的创世区块通过了主网的校验")
+		}
+		if SetChainParams("unknown") == nil {
+			t.Fatalf("\t设置了不存在的网络类型")
+		}
+		t.Log("\t创世区块与网络参数一致")
+	}
+}
@@ -0,0 +1,48 @@
+/*
+	共识引擎:区块的封装(挖矿或签名)、区块头的共识校验以及下一个出块者的选择都交给共识引擎完成,
+	目前支持工作量证明(pow)与权威证明(poa)两种共识,通过配置文件选择
+*/
+package block
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+type ConsensusEngine interface {
+	//共识名称
+	Name() string
+	//为新区块头填写共识相关的字段(如难度值)
+	Prepare(bc *blockchain, header *BlockHeader) error
+	//封装区块(pow进行挖矿,poa进行签名)并填写区块hash,ctx被取消时应尽快返回错误
+	Seal(ctx context.Context, bc *blockchain, block *Block) error
+	//依赖区块链状态的区块头共识校验
+	VerifyHeader(bc *blockchain, header *BlockHeader) error
+	//只依赖区块头本身的共识校验,用于暂时无法进行上下文校验的孤块
+	VerifySeal(header *BlockHeader) error
+	//获取指定高度区块的出块者地址,返回空字符串表示任何节点都可以出块
+	NextProducer(bc *blockchain, height int) string
+	//区块在分叉选择中计入的工作量
+	CalcWork(header *BlockHeader) *big.Int
+}
+
+//当前使用的共识引擎,默认为工作量证明
+var Engine ConsensusEngine = &powEngine{}
+
+//根据共识名称设置共识引擎,validators为poa共识中有权出块的地址列表
+func SetConsensusEngine(name string, validators []string) error {
+	switch name {
+	case "", "pow":
+		Engine = &powEngine{}
+	case "poa":
+		poa, err := newPoaEngine(validators)
+		if err != nil {
+			return err
+		}
+		Engine = poa
+	default:
+		return fmt.Errorf("SetConsensusEngine err : 不支持的共识类型%s", name)
+	}
+	return nil
+}
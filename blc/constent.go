@@ -1,20 +1,13 @@
 package block
 
-import (
-	"math"
-)
-
 //当前节点发现的网络中最新区块高度
 var NewestBlockHeight int
 
 //当前本地监听端口
 var ListenPort string
 
-//挖矿奖励代币数量
-var TokenRewardNum int
-
-//挖矿难度值
-var TargetBits uint
+//挖矿线程数,为0时使用全部cpu核心
+var MinerThreads int
 
 //中文助记词地址
 var ChineseMnwordPath string
@@ -25,14 +18,47 @@ const RewardAddrMapping = "rewardAddress"
 //最新区块Hash在数据库中的键
 const LastBlockHashMapping = "lastHash"
 
+//UTXO数据库当前对应的区块hash在undo仓库中的键
+const utxoTipMapping = "utxoTip"
+
 //钱包地址在数据库中的键
 const addrListMapping = "addressList"
 
-//公链版本信息默认为0
-const version = byte(0x00)
+//区块版本号
+const blockVersion = int32(1)
+
+//区块时间戳最多允许超前本地时间两小时
+const maxFutureBlockTime = 2 * 60 * 60
 
 //两次sha256(公钥hash)后截取的字节数量
 const checkSum = 4
 
-//随机数不能超过的最大值
-const maxInt = math.MaxInt64
+//计算过去中位时间时使用的区块数量
+const medianTimeBlocks = 11
+
+//时间锁的值小于该值时表示区块高度,否则表示unix时间戳
+const lockTimeThreshold = 500000000
+
+//输入的Sequence设置该标志位时,表示交易可以被手续费更高的冲突交易替换(RBF),其余位为相对时间锁
+const SequenceReplaceable = uint32(1 << 31)
+
+//单个输出金额与任何金额之和(交易的输入、输出总额,区块的手续费总额)的上限,超过时认为金额溢出
+const MaxMoney = 21000000 * 100000000
+
+//区块大小上限(字节):区块头与全部交易的大小之和
+const MaxBlockSize = 1000000
+
+//打包区块时为区块头与奖励交易预留的空间(字节),其余空间用于普通交易
+const BlockReservedSize = 1000
+
+//区块中最多包含的交易数量(包括奖励交易)
+const MaxBlockTransactions = 10000
+
+//一笔交易最多包含的输入数量
+const maxTxInputs = 1000
+
+//一笔交易最多包含的输出数量
+const maxTxOutputs = 1000
+
+//奖励交易中额外随机数的最大字节数
+const maxExtraNonceSize = 32
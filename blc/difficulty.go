@@ -0,0 +1,126 @@
+/*
+	挖矿难度:区块头中以比特币的compact格式(bits)保存本块难度,
+	每隔RetargetInterval个区块根据实际出块时间重新计算一次难度
+*/
+package block
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+//难度调整时,实际出块时间与期望时间的比值最多为4倍(或1/4)
+const retargetClamp = 4
+
+//由难度系数得到目标值:难度系数为1时目标值为最低难度的目标值,难度系数越大目标值越小
+func DifficultyToTarget(difficulty float64) *big.Int {
+	if difficulty <= 1 {
+		return new(big.Int).Set(ActiveParams.PowLimit)
+	}
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(ActiveParams.PowLimit), big.NewFloat(difficulty)).Int(nil)
+	return target
+}
+
+//将compact格式的难度值转换为目标大数
+//bits的最高字节为指数,低三个字节为尾数, target = 尾数 * 256^(指数-3)
+func CompactToBig(bits uint32) *big.Int {
+	mantissa := bits & 0x007fffff
+	isNegative := bits&0x00800000 != 0
+	exponent := uint(bits >> 24)
+	var target *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		target = big.NewInt(int64(mantissa))
+	} else {
+		target = big.NewInt(int64(mantissa))
+		target.Lsh(target, 8*(exponent-3))
+	}
+	if isNegative {
+		target = target.Neg(target)
+	}
+	return target
+}
+
+//将目标大数转换为compact格式的难度值
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() == 0 {
+		return 0
+	}
+	var mantissa uint32
+	exponent := uint(len(target.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(target.Bits()[0])
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tmp := new(big.Int).Set(target)
+		mantissa = uint32(tmp.Rsh(tmp, 8*(exponent-3)).Bits()[0])
+	}
+	//尾数最高位为符号位,如果被占用则尾数右移一个字节,指数加一
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	bits := uint32(exponent<<24) | mantissa
+	if target.Sign() < 0 {
+		bits |= 0x00800000
+	}
+	return bits
+}
+
+//计算单个难度值对应的工作量 work = 2^256 / (target+1)
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	target.Add(target, big.NewInt(1))
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	return work.Div(work, target)
+}
+
+//计算接在parent之后的区块应有的难度值
+func (bc *blockchain) calcNextRequiredBits(parent *BlockHeader) (uint32, error) {
+	//不在调整周期的区块沿用上一个区块的难度
+	if ActiveParams.RetargetInterval <= 1 || parent.Height%ActiveParams.RetargetInterval != 0 {
+		return parent.Bits, nil
+	}
+	//找到本周期的第一个区块
+	first := parent
+	for i := 0; i < ActiveParams.RetargetInterval-1; i++ {
+		first = bc.getHeader(first.PreHash)
+		if first == nil {
+			return 0, fmt.Errorf("calcNextRequiredBits err : 找不到高度%d之前的区块", parent.Height)
+		}
+	}
+	//计算实际耗时,并限制在期望时间的1/4到4倍之间
+	targetTimespan := int64(ActiveParams.RetargetInterval-1) * ActiveParams.TargetBlockTime
+	actualTimespan := parent.TimeStamp - first.TimeStamp
+	if actualTimespan < targetTimespan/retargetClamp {
+		actualTimespan = targetTimespan / retargetClamp
+	}
+	if actualTimespan > targetTimespan*retargetClamp {
+		actualTimespan = targetTimespan * retargetClamp
+	}
+	//新目标值 = 旧目标值 * 实际耗时 / 期望耗时
+	newTarget := CompactToBig(parent.Bits)
+	newTarget.Mul(newTarget, big.NewInt(actualTimespan))
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+	if newTarget.Cmp(ActiveParams.PowLimit) > 0 {
+		newTarget = ActiveParams.PowLimit
+	}
+	return BigToCompact(newTarget), nil
+}
+
+//获取共识规则要求该区块具有的难度值
+func (bc *blockchain) GetRequiredBits(header *BlockHeader) (uint32, error) {
+	//创世区块没有上一个区块,难度值由网络参数固定
+	if isGenesisHeader(header) {
+		return ActiveParams.GenesisBits(), nil
+	}
+	parent := bc.getHeader(header.PreHash)
+	if parent == nil {
+		return 0, errors.New("GetRequiredBits err : 找不到上一个区块")
+	}
+	return bc.calcNextRequiredBits(parent)
+}
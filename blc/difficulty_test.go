@@ -0,0 +1,38 @@
+package block
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCompactBits(t *testing.T) {
+	t.Log("测试compact格式难度值与目标大数的互相转换")
+	{
+		//比特币创世区块的难度值
+		target := CompactToBig(0x1d00ffff)
+		expect, _ := new(big.Int).SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+		if target.Cmp(expect) != 0 {
+			t.Fatalf("\t转换结果不正确：%x", target)
+		}
+		if bits := BigToCompact(target); bits != 0x1d00ffff {
+			t.Fatalf("\t反向转换结果不正确：%08x", bits)
+		}
+		//尾数最高位被占用时需要进位
+		if bits := BigToCompact(big.NewInt(0x80)); bits != 0x02008000 {
+			t.Fatalf("\t进位结果不正确：%08x", bits)
+		}
+		t.Log("\t转换结果正确")
+	}
+}
+
+func TestCalcWork(t *testing.T) {
+	t.Log("测试难度值对应的工作量")
+	{
+		easy := CalcWork(BigToCompact(new(big.Int).Lsh(big.NewInt(1), 240)))
+		hard := CalcWork(BigToCompact(new(big.Int).Lsh(big.NewInt(1), 230)))
+		if hard.Cmp(easy) <= 0 {
+			t.Fatal("\t难度越大工作量应该越大")
+		}
+		t.Logf("\t工作量：%s %s", easy, hard)
+	}
+}
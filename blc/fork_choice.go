@@ -0,0 +1,262 @@
+/*
+	分叉选择:本地链始终跟随累计工作量最大的分支,
+	当出现更重的侧链时,先将旧链区块回滚到共同祖先,再依次接入新分支的区块
+*/
+package block
+
+import (
+	"bytes"
+	"errors"
+	"github.com/corgi-kx/blockchain_golang/database"
+	log "github.com/corgi-kx/logcustom"
+	"math/big"
+	"sync"
+)
+
+//区块的添加与链重组需要串行进行
+var chainLock = sync.Mutex{}
+
+//添加区块信息到数据库,如果该区块所在分支的累计工作量最大,则切换到该分支并同步UTXO数据库
+//区块在存入前会进行与状态无关的校验,接入主链前会进行完整的共识校验
+func (bc *blockchain) AddBlock(block *Block) error {
+	chainLock.Lock()
+	defer chainLock.Unlock()
+	if len(bc.GetBlockByHash(block.Hash)) != 0 {
+		log.Debugf("区块%x已存在于本地库中,无需重复添加", block.Hash)
+		return nil
+	}
+	//计算本块所在分支的累计工作量
+	parentWork := big.NewInt(0)
+	if !isGenesisBlock(block) {
+		if len(bc.GetBlockByHash(block.PreHash)) == 0 {
+			return newValidationError(RejectMissingParent, "找不到区块%x的上一个区块%x", block.Hash, block.PreHash)
+		}
+		parentWork = bc.getChainWork(block.PreHash)
+	}
+	err := bc.checkBlockSanity(block)
+	if err != nil {
+		return err
+	}
+	err = bc.checkBlockHeader(block)
+	if err != nil {
+		return err
+	}
+	chainWork := new(big.Int).Add(parentWork, calcBlockWork(block))
+	bc.BD.Put(block.Hash, block.Serialize(), database.BlockBucket)
+	bc.BD.Put(block.Hash, block.BlockHeader.Serialize(), database.HeaderBucket)
+	bc.BD.Put(block.Hash, chainWork.Bytes(), database.WorkBucket)
+
+	tipHash := bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)
+	if len(tipHash) != 0 {
+		tipWork := bc.getChainWork(tipHash)
+		if chainWork.Cmp(tipWork) <= 0 {
+			log.Infof("区块%x(高度%d)所在分支累计工作量不大于主链,暂作为侧链保存", block.Hash, block.Height)
+			return nil
+		}
+		//侧链工作量超过主链,进行链重组
+		if !bytes.Equal(block.PreHash, tipHash) {
+			return bc.reorganize(tipHash, block)
+		}
+	}
+	//本地还没有区块或直接延长主链
+	err = bc.ValidateBlock(block)
+	if err != nil {
+		bc.removeBlock(block.Hash)
+		return err
+	}
+	bc.connectBlock(block)
+	return nil
+}
+
+//将区块接入主链末端:同步UTXO数据库、主链高度索引并更新lastHash,全部在同一个事务中提交
+func (bc *blockchain) connectBlock(block *Block) {
+	u := UTXOHandle{bc}
+	batch := bc.BD.NewBatch()
+	u.ConnectBlock(block, batch)
+	batch.Put(heightKey(block.Height), block.Hash, database.HeightBucket)
+	batch.Put([]byte(LastBlockHashMapping), block.Hash, database.BlockBucket)
+	batch.Commit()
+	if block.Height > NewestBlockHeight {
+		NewestBlockHeight = block.Height
+	}
+	//最新区块已变化,正在挖的区块已经过时
+	CancelMining()
+	notifyBlockConnected(block)
+}
+
+//将主链末端的区块断开:回滚UTXO数据库、删除高度索引并将lastHash指向上一个区块,全部在同一个事务中提交
+func (bc *blockchain) disconnectBlock(block *Block) {
+	u := UTXOHandle{bc}
+	batch := bc.BD.NewBatch()
+	u.DisconnectBlock(block, batch)
+	batch.Delete(heightKey(block.Height), database.HeightBucket)
+	batch.Put([]byte(LastBlockHashMapping), block.PreHash, database.BlockBucket)
+	batch.Commit()
+	notifyBlockDisconnected(block)
+}
+
+//链重组:将主链回滚到与新分支的共同祖先,再依次校验并接入新分支的区块
+//新分支中有区块没有通过校验时,恢复原来的主链,并删除无效区块及其之后的区块
+func (bc *blockchain) reorganize(tipHash []byte, newTip *Block) error {
+	forkBlock, err := bc.findForkBlock(tipHash, newTip.Hash)
+	if err != nil {
+		return err
+	}
+	//收集新分支上从共同祖先到新区块的全部区块
+	attach := []*Block{}
+	for current := newTip; !bytes.Equal(current.Hash, forkBlock.Hash); {
+		attach = append(attach, current)
+		current = bc.getBlock(current.PreHash)
+		if current == nil {
+			return errors.New("reorganize err : 新分支上的区块不完整")
+		}
+	}
+	//回滚旧主链
+	detach := []*Block{}
+	for current := bc.getBlock(tipHash); !bytes.Equal(current.Hash, forkBlock.Hash); current = bc.getBlock(current.PreHash) {
+		bc.disconnectBlock(current)
+		detach = append(detach, current)
+	}
+	//按高度从低到高接入新分支
+	for i := len(attach) - 1; i >= 0; i-- {
+		err = bc.ValidateBlock(attach[i])
+		if err == nil {
+			bc.connectBlock(attach[i])
+			continue
+		}
+		log.Errorf("链重组失败,新分支区块%x(高度%d)没有通过校验:%s", attach[i].Hash, attach[i].Height, err)
+		//断开已接入的新分支区块
+		for j := i + 1; j < len(attach); j++ {
+			bc.disconnectBlock(attach[j])
+		}
+		//恢复原来的主链
+		for j := len(detach) - 1; j >= 0; j-- {
+			bc.connectBlock(detach[j])
+		}
+		//删除无效区块以及在它之后的区块
+		for j := i; j >= 0; j-- {
+			bc.removeBlock(attach[j].Hash)
+		}
+		return err
+	}
+	log.Infof("链重组完成,共同祖先高度为%d,回滚%d个区块,接入%d个区块,当前最新区块hash为%x", forkBlock.Height, len(detach), len(attach), newTip.Hash)
+	return nil
+}
+
+//从本地库中删除没有通过校验的区块
+func (bc *blockchain) removeBlock(hash []byte) {
+	bc.BD.Delete(hash, database.BlockBucket)
+	bc.BD.Delete(hash, database.HeaderBucket)
+	bc.BD.Delete(hash, database.WorkBucket)
+}
+
+//找到两个区块所在分支的共同祖先
+func (bc *blockchain) findForkBlock(hashA, hashB []byte) (*Block, error) {
+	blockA := bc.getBlock(hashA)
+	blockB := bc.getBlock(hashB)
+	for blockA != nil && blockB != nil && !bytes.Equal(blockA.Hash, blockB.Hash) {
+		if blockA.Height >= blockB.Height {
+			blockA = bc.getBlock(blockA.PreHash)
+		} else {
+			blockB = bc.getBlock(blockB.PreHash)
+		}
+	}
+	if blockA == nil || blockB == nil {
+		return nil, errors.New("findForkBlock err : 两条分支没有共同的祖先区块")
+	}
+	return blockA, nil
+}
+
+//获取区块所在分支的累计工作量,旧版本数据库中没有记录的话会沿着区块向前补算
+func (bc *blockchain) getChainWork(hash []byte) *big.Int {
+	workBytes := bc.BD.View(hash, database.WorkBucket)
+	if len(workBytes) != 0 {
+		return new(big.Int).SetBytes(workBytes)
+	}
+	header := bc.getHeader(hash)
+	if header == nil {
+		return big.NewInt(0)
+	}
+	work := Engine.CalcWork(header)
+	if !isGenesisHeader(header) {
+		work.Add(work, bc.getChainWork(header.PreHash))
+	}
+	bc.BD.Put(hash, work.Bytes(), database.WorkBucket)
+	return work
+}
+
+//计算单个区块的工作量
+func calcBlockWork(block *Block) *big.Int {
+	return Engine.CalcWork(&block.BlockHeader)
+}
+
+//通过hash获取区块对象,找不到则返回nil
+func (bc *blockchain) getBlock(hash []byte) *Block {
+	blockBytes := bc.GetBlockByHash(hash)
+	if len(blockBytes) == 0 {
+		return nil
+	}
+	block := &Block{}
+	block.Deserialize(blockBytes)
+	return block
+}
+
+//生成区块定位器:从最新区块开始,前十个逐个取,之后步长翻倍,最后一定包含创世区块
+func (bc *blockchain) GetBlockLocator() [][]byte {
+	locator := [][]byte{}
+	step := 1
+	bci := NewBlockchainIterator(bc)
+	for {
+		block := bci.Next()
+		if block == nil {
+			return locator
+		}
+		locator = append(locator, block.Hash)
+		if isGenesisBlock(block) {
+			return locator
+		}
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		//跳过step-1个区块,但不能越过创世区块
+		for i := 1; i < step; i++ {
+			block = bci.Next()
+			if block == nil {
+				return locator
+			}
+			if isGenesisBlock(block) {
+				return append(locator, block.Hash)
+			}
+		}
+	}
+}
+
+//判断区块是否处于本地主链上
+func (bc *blockchain) IsInMainChain(hash []byte) bool {
+	header := bc.getHeader(hash)
+	if header == nil {
+		return false
+	}
+	return bytes.Equal(bc.GetBlockHashByHeight(header.Height), hash)
+}
+
+//旧版本数据库没有主链高度索引,节点启动时沿着主链补上
+func (bc *blockchain) RecoverHeightIndex() {
+	tipHash := bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)
+	if len(tipHash) == 0 {
+		return
+	}
+	tip := bc.getHeader(tipHash)
+	if bytes.Equal(bc.GetBlockHashByHeight(tip.Height), tipHash) {
+		return
+	}
+	log.Info("主链高度索引不完整,开始重建")
+	batch := bc.BD.NewBatch()
+	for header := tip; header != nil; header = bc.getHeader(header.PreHash) {
+		batch.Put(heightKey(header.Height), header.CalcHash(), database.HeightBucket)
+		if isGenesisHeader(header) {
+			break
+		}
+	}
+	batch.Commit()
+}
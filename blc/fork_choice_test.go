@@ -0,0 +1,113 @@
+package block
+
+import (
+	"bytes"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"reflect"
+	"testing"
+)
+
+//添加区块,失败时终止测试
+func addTestBlock(t *testing.T, bc *blockchain, b *Block) {
+	err := bc.AddBlock(b)
+	if err != nil {
+		t.Fatalf("\t添加区块%x(高度%d)失败:%s", b.Hash, b.Height, err)
+	}
+}
+
+func tipHash(bc *blockchain) []byte {
+	return bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)
+}
+
+//创建主链 创世区块 -> A1(奖励给miner) -> A2(将A1的奖励转给payee)
+func newTestMainChain(t *testing.T, bc *blockchain, miner, payee *bitcoinKeys) (*Block, *Block) {
+	genesis := ActiveParams.GenesisBlock()
+	a1 := mineTestBlock(t, bc, genesis, miner, nil, 'a')
+	addTestBlock(t, bc, a1)
+	a2 := mineTestBlock(t, bc, a1, miner, []Transaction{newTestPayment(miner, &a1.Transactions[0], 0, payee, 0)}, 'a')
+	addTestBlock(t, bc, a2)
+	return a1, a2
+}
+
+func TestReorganize(t *testing.T) {
+	t.Log("测试工作量更大的侧链触发链重组,重组后的utxo数据库与重新同步的结果一致")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		a1, _ := newTestMainChain(t, bc, miner, payee)
+		genesis := ActiveParams.GenesisBlock()
+		b1 := mineTestBlock(t, bc, genesis, payee, nil, 'b')
+		addTestBlock(t, bc, b1)
+		b2 := mineTestBlock(t, bc, b1, payee, []Transaction{newTestPayment(payee, &b1.Transactions[0], 0, miner, 0)}, 'b')
+		addTestBlock(t, bc, b2)
+		b3 := mineTestBlock(t, bc, b2, payee, nil, 'b')
+		addTestBlock(t, bc, b3)
+		if !bytes.Equal(tipHash(bc), b3.Hash) || !bc.IsInMainChain(b1.Hash) || bc.IsInMainChain(a1.Hash) {
+			t.Fatalf("\t工作量更大的侧链没有成为主链")
+		}
+		utxos := dumpTestUTXOs(t, bc)
+		if _, ok := utxos[string(a1.Transactions[0].TxHash)]; ok {
+			t.Fatalf("\t回滚的区块中的输出仍在utxo数据库中")
+		}
+		u := UTXOHandle{bc}
+		u.ResetUTXODataBase()
+		if !reflect.DeepEqual(utxos, dumpTestUTXOs(t, bc)) {
+			t.Fatalf("\t链重组后的utxo数据库与重新同步的结果不一致")
+		}
+		t.Log("\t链重组正确")
+	}
+}
+
+func TestReorganizeInvalidBlock(t *testing.T) {
+	t.Log("测试链重组时新分支的区块没有通过校验,恢复原来的主链与utxo数据库,并删除无效区块")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		_, a2 := newTestMainChain(t, bc, miner, payee)
+		utxos := dumpTestBucket(t, database.UTXOBucket)
+		genesis := ActiveParams.GenesisBlock()
+		b1 := mineTestBlock(t, bc, genesis, payee, nil, 'b')
+		addTestBlock(t, bc, b1)
+		//花费一个不存在的输出,只有接入主链时才能发现
+		bad := Transaction{nil, []TXInput{{[]byte("missing"), 0, nil, 0}}, []TXOutput{{1, []byte("to")}}, 0}
+		bad.hash()
+		b2 := mineTestBlock(t, bc, b1, payee, []Transaction{bad}, 'b')
+		addTestBlock(t, bc, b2)
+		b3 := mineTestBlock(t, bc, b2, payee, nil, 'b')
+		if bc.AddBlock(b3) == nil {
+			t.Fatalf("\t包含无效区块的分支重组成功")
+		}
+		if !bytes.Equal(tipHash(bc), a2.Hash) {
+			t.Fatalf("\t重组失败后没有恢复原来的主链")
+		}
+		if !reflect.DeepEqual(utxos, dumpTestBucket(t, database.UTXOBucket)) {
+			t.Fatalf("\t重组失败后utxo数据库与重组前不一致")
+		}
+		if bc.getBlock(b2.Hash) != nil || bc.getBlock(b3.Hash) != nil || bc.getBlock(b1.Hash) == nil {
+			t.Fatalf("\t重组失败后应只删除无效区块及其之后的区块")
+		}
+		t.Log("\t重组失败后已恢复原来的主链")
+	}
+}
+
+func TestEqualWorkFork(t *testing.T) {
+	t.Log("测试工作量相同的分叉不触发链重组")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		_, a2 := newTestMainChain(t, bc, miner, payee)
+		utxos := dumpTestBucket(t, database.UTXOBucket)
+		genesis := ActiveParams.GenesisBlock()
+		b1 := mineTestBlock(t, bc, genesis, payee, nil, 'b')
+		addTestBlock(t, bc, b1)
+		b2 := mineTestBlock(t, bc, b1, payee, nil, 'b')
+		addTestBlock(t, bc, b2)
+		if !bytes.Equal(tipHash(bc), a2.Hash) || bc.IsInMainChain(b2.Hash) {
+			t.Fatalf("\t工作量相同的分叉触发了链重组")
+		}
+		if !reflect.DeepEqual(utxos, dumpTestBucket(t, database.UTXOBucket)) {
+			t.Fatalf("\t没有重组时utxo数据库发生了变化")
+		}
+		t.Log("\t工作量相同时保留原来的主链")
+	}
+}
@@ -0,0 +1,179 @@
+/*
+	多线程挖矿:每个挖矿线程使用nonce的不同高位,在各自的区间内顺序递增nonce,
+	一轮nonce用尽后更新时间戳与奖励交易中的额外随机数,得到新的区块头继续挖矿;
+	最新区块发生变化时通过context取消正在进行的挖矿,并定时统计挖矿算力
+*/
+package block
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/corgi-kx/blockchain_golang/util"
+	log "github.com/corgi-kx/logcustom"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//每个挖矿线程每一轮尝试的nonce数量,nonce的高位为线程编号
+const noncesPerRound = 1 << 32
+
+//挖矿线程每计算多少次hash检查一次是否被取消并累计算力
+const hashBatch = 1 << 12
+
+//统计算力的间隔(秒)
+const hashRateInterval = 5
+
+//当前最新区块对应的挖矿上下文,最新区块变化时取消并重新生成
+var tipLock = sync.Mutex{}
+var tipCtx, tipCancel = context.WithCancel(context.Background())
+
+//最近一次统计的算力(hash/秒),以float64的bit形式保存
+var hashRate uint64
+
+//获取当前最新区块对应的挖矿上下文
+func miningContext() context.Context {
+	tipLock.Lock()
+	defer tipLock.Unlock()
+	return tipCtx
+}
+
+//取消正在进行的挖矿(最新区块发生了变化或网络中出现了更高的区块)
+func CancelMining() {
+	tipLock.Lock()
+	defer tipLock.Unlock()
+	tipCancel()
+	tipCtx, tipCancel = context.WithCancel(context.Background())
+}
+
+//获取最近一次统计的挖矿算力(hash/秒)
+func GetHashRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&hashRate))
+}
+
+func setHashRate(rate float64) {
+	atomic.StoreUint64(&hashRate, math.Float64bits(rate))
+}
+
+//获取挖矿线程数
+func GetMinerThreads() int {
+	if MinerThreads > 0 {
+		return MinerThreads
+	}
+	return runtime.NumCPU()
+}
+
+//启动多个挖矿线程,任意一个线程找到满足难度的区块头后,将结果写回block
+func mine(ctx context.Context, block *Block) error {
+	threads := GetMinerThreads()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan *Block, threads)
+	var hashes uint64
+	wg := sync.WaitGroup{}
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			mineWorker(ctx, block, worker, &hashes, found)
+		}(i)
+	}
+	log.Infof("准备挖矿,挖矿区块高度为%d,挖矿线程数%d...", block.Height, threads)
+	start := time.Now()
+	ticker := time.NewTicker(hashRateInterval * time.Second)
+	defer ticker.Stop()
+	var result *Block
+	var last uint64
+	for result == nil && ctx.Err() == nil {
+		select {
+		case result = <-found:
+		case <-ctx.Done():
+		case <-ticker.C:
+			total := atomic.LoadUint64(&hashes)
+			setHashRate(float64(total-last) / hashRateInterval)
+			last = total
+			log.Infof("正在挖矿,挖矿区块高度为%d,已经运行%ds,当前算力%.0f H/s", block.Height, int(time.Since(start).Seconds()), GetHashRate())
+		}
+	}
+	//被取消的同时可能已经有线程挖到了区块
+	if result == nil {
+		select {
+		case result = <-found:
+		default:
+		}
+	}
+	cancel()
+	wg.Wait()
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		setHashRate(float64(atomic.LoadUint64(&hashes)) / elapsed)
+	}
+	if result == nil {
+		return errors.New("检测到最新区块已变化，所以终止此块的挖矿操作")
+	}
+	*block = *result
+	log.Infof("本节点已成功挖到区块!!!,高度为:%d,nonce值为:%d,区块hash为: %x", block.Height, block.Nonce, block.Hash)
+	return nil
+}
+
+//单个挖矿线程:nonce高位为线程编号,低32位从0开始递增
+func mineWorker(ctx context.Context, template *Block, worker int, hashes *uint64, found chan<- *Block) {
+	block := copyBlockTemplate(template)
+	target := CompactToBig(block.Bits)
+	coinbase := -1
+	for i := range block.Transactions {
+		if block.Transactions[i].IsCoinbase() {
+			coinbase = i
+		}
+	}
+	var hashInt big.Int
+	for round := uint64(0); ; round++ {
+		if round > 0 {
+			//本轮nonce已用尽,更新时间戳与额外随机数
+			if now := time.Now().Unix(); now > block.TimeStamp {
+				block.TimeStamp = now
+			}
+			if coinbase >= 0 {
+				block.Transactions[coinbase].setExtraNonce(block.Height, util.Int64ToBytes(int64(round)))
+				block.MerkleRoot = calcMerkleRoot(block.Transactions)
+			}
+		}
+		//挖矿时直接在区块头拼接数据上替换nonce
+		data, offset := block.WorkData()
+		for counter := uint64(0); counter < noncesPerRound; counter++ {
+			if counter%hashBatch == 0 {
+				if ctx.Err() != nil {
+					return
+				}
+				atomic.AddUint64(hashes, hashBatch)
+			}
+			nonce := int64(worker)<<32 | int64(counter)
+			binary.BigEndian.PutUint64(data[offset:], uint64(nonce))
+			hash := sha256.Sum256(data)
+			hashInt.SetBytes(hash[:])
+			if hashInt.Cmp(target) == -1 {
+				block.Nonce = nonce
+				block.Hash = hash[:]
+				found <- block
+				return
+			}
+		}
+	}
+}
+
+//拷贝区块模板,每个挖矿线程单独修改自己的奖励交易与区块头
+func copyBlockTemplate(template *Block) *Block {
+	block := *template
+	block.Transactions = make([]Transaction, len(template.Transactions))
+	copy(block.Transactions, template.Transactions)
+	for i := range block.Transactions {
+		if block.Transactions[i].IsCoinbase() {
+			block.Transactions[i].Vint = append([]TXInput{}, template.Transactions[i].Vint...)
+		}
+	}
+	return &block
+}
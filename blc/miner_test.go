@@ -0,0 +1,42 @@
+package block
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func newTestTemplate(bits uint32) *Block {
+	coinbase := Transaction{nil, []TXInput{newCoinbaseInput(2)}, []TXOutput{{10, []byte("miner")}}, 0}
+	coinbase.hash()
+	tss := []Transaction{coinbase}
+	header := BlockHeader{blockVersion, make([]byte, 32), calcMerkleRoot(tss), 1, bits, 0, 2, nil, nil}
+	return &Block{header, tss, nil}
+}
+
+func TestMine(t *testing.T) {
+	t.Log("测试多线程挖矿找到满足难度的区块头")
+	{
+		MinerThreads = 4
+		defer func() { MinerThreads = 0 }()
+		b := newTestTemplate(BigToCompact(ActiveParams.PowLimit))
+		err := mine(context.Background(), b)
+		if err != nil {
+			t.Fatalf("\t挖矿失败：%s", err)
+		}
+		if !NewProofOfWork(&b.BlockHeader).checkHash() || string(b.Hash) != string(b.CalcHash()) {
+			t.Fatalf("\t挖到的区块不满足工作量证明")
+		}
+		t.Log("\t挖到的区块满足工作量证明")
+	}
+	t.Log("测试取消挖矿")
+	{
+		b := newTestTemplate(BigToCompact(big.NewInt(1)))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if mine(ctx, b) == nil {
+			t.Fatalf("\t挖矿被取消后不应返回区块")
+		}
+		t.Log("\t挖矿已被取消")
+	}
+}
@@ -0,0 +1,188 @@
+/*
+	多重签名:由n个公钥与签名数量m生成m-of-n赎回脚本,多重签名地址为赎回脚本hash的P2SH地址
+	花费多重签名地址的余额需要多方协作:由一方创建待签名交易,各方分别用自己的私钥签名,
+	合并各方的签名后,凑够m个签名即可生成最终的解锁脚本并广播交易
+*/
+package block
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+//多方协作签名中的交易
+type PartialTransaction struct {
+	Tx Transaction
+	//交易所有输入花费的都是该赎回脚本对应的多重签名地址
+	RedeemScript []byte
+	//每个输入已收集到的签名 key:公钥的十六进制
+	Signatures []map[string][]byte
+}
+
+//根据签名数量m与公钥列表生成多重签名地址与赎回脚本
+func CreateMultisigAddress(m int, publicKeys [][]byte) (string, []byte, error) {
+	redeemScript, err := NewMultiSigScript(m, publicKeys)
+	if err != nil {
+		return "", nil, err
+	}
+	return GetAddressFromScriptHash(generatePublicKeyHash(redeemScript)), redeemScript, nil
+}
+
+//创建花费多重签名地址余额的待签名交易,找零返回多重签名地址,fee为支付给矿工的手续费
+func (bc *blockchain) CreateMultisigTransaction(redeemScript []byte, to string, amount, fee int) (*PartialTransaction, error) {
+	if _, _, err := parseMultiSigScript(redeemScript); err != nil {
+		return nil, err
+	}
+	if !IsVaildBitcoinAddress(to) {
+		return nil, fmt.Errorf("地址格式不正确:%s", to)
+	}
+	if amount <= 0 || fee < 0 {
+		return nil, errors.New("转账金额必须大于0且手续费不可小于0")
+	}
+	from := GetAddressFromScriptHash(generatePublicKeyHash(redeemScript))
+	u := UTXOHandle{bc}
+	utxos := u.findSpendableUTXOFromAddress(from)
+	need := amount + fee
+	vIn := []TXInput{}
+	total := 0
+	for _, utxo := range utxos {
+		vIn = append(vIn, TXInput{utxo.Hash, utxo.Index, nil, 0})
+		total += utxo.Vout.Value
+		if total >= need {
+			break
+		}
+	}
+	if total < need {
+		return nil, fmt.Errorf("多重签名地址%s可用余额%d不足%d", from, total, need)
+	}
+	vOut := []TXOutput{{amount, NewScriptForAddress(to)}}
+	if total > need {
+		vOut = append(vOut, TXOutput{total - need, NewP2SHScript(generatePublicKeyHash(redeemScript))})
+	}
+	ts := Transaction{nil, vIn, vOut, 0}
+	ts.hash()
+	signatures := make([]map[string][]byte, len(vIn))
+	for i := range signatures {
+		signatures[i] = map[string][]byte{}
+	}
+	return &PartialTransaction{ts, redeemScript, signatures}, nil
+}
+
+//使用本地钱包中地址address的私钥对交易的每个输入签名,返回签名的输入数量
+func (bc *blockchain) SignPartialTransaction(p *PartialTransaction, address string) (int, error) {
+	keys, ok := NewWallets(bc.BD).Wallets[address]
+	if !ok {
+		return 0, fmt.Errorf("没有找到地址%s所对应的私钥", address)
+	}
+	return p.sign(keys)
+}
+
+func (p *PartialTransaction) sign(keys *bitcoinKeys) (int, error) {
+	_, publicKeys, err := parseMultiSigScript(p.RedeemScript)
+	if err != nil {
+		return 0, err
+	}
+	found := false
+	for _, publicKey := range publicKeys {
+		if bytes.Equal(publicKey, keys.PublicKey) {
+			found = true
+		}
+	}
+	if !found {
+		return 0, errors.New("该地址的公钥不在多重签名的公钥列表中")
+	}
+	//签名hash中被花费输入的解锁脚本替换为赎回脚本,与脚本执行时一致
+	for i := range p.Tx.Vint {
+		p.Signatures[i][hex.EncodeToString(keys.PublicKey)] = ellipticCurveSign(keys.PrivateKey, p.Tx.signatureHash(i, p.RedeemScript))
+	}
+	return len(p.Tx.Vint), nil
+}
+
+//合并各方签名后的同一笔交易
+func CombinePartialTransactions(ps []*PartialTransaction) (*PartialTransaction, error) {
+	if len(ps) == 0 {
+		return nil, errors.New("没有需要合并的交易")
+	}
+	first := ps[0]
+	for _, p := range ps[1:] {
+		if !bytes.Equal(p.Tx.TxHash, first.Tx.TxHash) || !bytes.Equal(p.RedeemScript, first.RedeemScript) {
+			return nil, fmt.Errorf("交易%x与交易%x不是同一笔交易", p.Tx.TxHash, first.Tx.TxHash)
+		}
+		for i := range p.Signatures {
+			for k, v := range p.Signatures[i] {
+				first.Signatures[i][k] = v
+			}
+		}
+	}
+	return first, nil
+}
+
+//每个输入是否都已收集到足够的签名
+func (p *PartialTransaction) IsComplete() bool {
+	_, err := p.Finalize()
+	return err == nil
+}
+
+//按赎回脚本中公钥的顺序选取m个签名,生成每个输入的解锁脚本: OP_0 <签名1>...<签名m> <赎回脚本>
+func (p *PartialTransaction) Finalize() (*Transaction, error) {
+	m, publicKeys, err := parseMultiSigScript(p.RedeemScript)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Tx.VerifyTxHash() || len(p.Signatures) != len(p.Tx.Vint) {
+		return nil, errors.New("交易数据不完整")
+	}
+	ts := p.Tx.customCopy()
+	for i := range ts.Vint {
+		//OP_CHECKMULTISIG会多弹出一个元素,需要压入一个空的占位元素
+		scriptSig := []byte{OP_0}
+		count := 0
+		for _, publicKey := range publicKeys {
+			if signature, ok := p.Signatures[i][hex.EncodeToString(publicKey)]; ok && count < m {
+				scriptSig = append(scriptSig, pushData(signature)...)
+				count++
+			}
+		}
+		if count < m {
+			return nil, fmt.Errorf("第%d个输入只有%d个签名,需要%d个", i+1, count, m)
+		}
+		ts.Vint[i].ScriptSig = append(scriptSig, pushData(p.RedeemScript)...)
+	}
+	return &ts, nil
+}
+
+//序列化为十六进制字符串,便于在各方之间传递
+func (p *PartialTransaction) Serialize() string {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+	err := encoder.Encode(p)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(result.Bytes())
+}
+
+func DeserializePartialTransaction(data string) (*PartialTransaction, error) {
+	b, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, errors.New("交易数据不是十六进制编码")
+	}
+	p := &PartialTransaction{}
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(p)
+	if err != nil {
+		return nil, fmt.Errorf("交易数据解析失败:%s", err)
+	}
+	if len(p.Signatures) != len(p.Tx.Vint) {
+		return nil, errors.New("交易数据不完整")
+	}
+	//gob不会保留空的map
+	for i := range p.Signatures {
+		if p.Signatures[i] == nil {
+			p.Signatures[i] = map[string][]byte{}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,85 @@
+package block
+
+import (
+	"testing"
+)
+
+func TestMultisigAddress(t *testing.T) {
+	t.Log("测试多重签名地址的生成与校验")
+	{
+		keys := []*bitcoinKeys{newTestKeys(t), newTestKeys(t), newTestKeys(t)}
+		address, redeemScript, err := CreateMultisigAddress(2, [][]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsVaildBitcoinAddress(address) || !isScriptHashAddress(address) {
+			t.Fatalf("\t多重签名地址%s校验不通过", address)
+		}
+		if isScriptHashAddress(string(keys[0].getAddress())) {
+			t.Fatalf("\t普通地址被识别为多重签名地址")
+		}
+		out := TXOutput{1, NewScriptForAddress(address)}
+		if out.Address() != address || extractScriptHash(out.ScriptPubKey) == nil {
+			t.Fatalf("\t多重签名地址生成的锁定脚本不正确")
+		}
+		if m, publicKeys, err := parseMultiSigScript(redeemScript); err != nil || m != 2 || len(publicKeys) != 3 {
+			t.Fatalf("\t赎回脚本解析不正确")
+		}
+		if _, _, err := CreateMultisigAddress(4, [][]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey}); err == nil {
+			t.Fatalf("\tm大于n时生成了多重签名地址")
+		}
+		t.Log("\t多重签名地址正确")
+	}
+}
+
+func TestPartialTransaction(t *testing.T) {
+	t.Log("测试2-of-3多重签名交易的分别签名、合并与脚本验证")
+	{
+		keys := []*bitcoinKeys{newTestKeys(t), newTestKeys(t), newTestKeys(t)}
+		address, redeemScript, err := CreateMultisigAddress(2, [][]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		prevOut := TXOutput{10, NewScriptForAddress(address)}
+		ts := Transaction{nil, []TXInput{{[]byte("prev"), 0, nil, 0}}, []TXOutput{{9, NewP2PKHScript(generatePublicKeyHash(keys[0].PublicKey))}}, 0}
+		ts.hash()
+		newPartial := func() *PartialTransaction {
+			p, err := DeserializePartialTransaction((&PartialTransaction{ts, redeemScript, []map[string][]byte{{}}}).Serialize())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+		//两方分别签名
+		a, b := newPartial(), newPartial()
+		if _, err := a.sign(keys[2]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.sign(newTestKeys(t)); err == nil {
+			t.Fatalf("\t不在公钥列表中的私钥完成了签名")
+		}
+		if a.IsComplete() {
+			t.Fatalf("\t只有一个签名时交易被认为签名完成")
+		}
+		if _, err := b.sign(keys[0]); err != nil {
+			t.Fatal(err)
+		}
+		p, err := CombinePartialTransactions([]*PartialTransaction{a, b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		final, err := p.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := final.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\t合并签名后的交易没有通过脚本验证:%s", err)
+		}
+		//赎回脚本被替换后不能通过验证
+		other, _, _ := CreateMultisigAddress(1, [][]byte{keys[0].PublicKey})
+		if final.verifyInputScript(0, TXOutput{10, NewScriptForAddress(other)}) == nil {
+			t.Fatalf("\t赎回脚本hash不一致时通过了脚本验证")
+		}
+		t.Log("\t多重签名交易验证正确")
+	}
+}
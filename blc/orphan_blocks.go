@@ -0,0 +1,140 @@
+/*
+	孤块池:上一个区块尚未到达的区块先暂存在孤块池中,按缺失的上一个区块hash索引,
+	上一个区块加入区块链后再依次处理;孤块池有数量上限,且孤块超时后会被丢弃
+*/
+package block
+
+import (
+	log "github.com/corgi-kx/logcustom"
+	"sync"
+	"time"
+)
+
+//孤块池中最多存放的区块数量
+const maxOrphanBlocks = 100
+
+//孤块在孤块池中最多停留的时间(秒)
+const orphanBlockExpiry = 60 * 60
+
+type orphanBlock struct {
+	block *Block
+	//失效时间
+	expire int64
+}
+
+var orphanLock = sync.Mutex{}
+
+//key:区块hash
+var orphanBlocks = map[string]*orphanBlock{}
+
+//key:缺失的上一个区块hash
+var orphansByPrev = map[string][]*orphanBlock{}
+
+//处理接收到的区块:上一个区块尚未到达时存入孤块池,否则加入区块链,并接着处理以该区块为上一个区块的孤块
+//返回值表示区块是否被存入了孤块池
+func (bc *blockchain) ProcessBlock(block *Block) (bool, error) {
+	if !isGenesisBlock(block) && len(bc.GetBlockByHash(block.PreHash)) == 0 {
+		//孤块无法进行上下文校验,至少保证区块hash、默克尔根正确且满足共识规则(工作量证明或出块者签名)
+		err := bc.checkBlockSanity(block)
+		if err != nil {
+			return false, err
+		}
+		err = Engine.VerifySeal(&block.BlockHeader)
+		if err != nil {
+			return false, newValidationError(RejectBadHeader, "孤块%x没有通过共识校验:%s", block.Hash, err)
+		}
+		addOrphanBlock(block)
+		//检查与存入之间上一个区块可能刚好到达,它已经处理过孤块池,需要再检查一次
+		if len(bc.GetBlockByHash(block.PreHash)) != 0 {
+			bc.processOrphanBlocks(block.PreHash)
+			return len(bc.GetBlockByHash(block.Hash)) == 0, nil
+		}
+		return true, nil
+	}
+	err := bc.AddBlock(block)
+	if err != nil {
+		return false, err
+	}
+	bc.processOrphanBlocks(block.Hash)
+	return false, nil
+}
+
+//依次处理以hash为上一个区块的孤块,以及这些孤块的后代孤块
+func (bc *blockchain) processOrphanBlocks(hash []byte) {
+	queue := [][]byte{hash}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, ob := range takeOrphanBlocks(parent) {
+			err := bc.AddBlock(ob.block)
+			if err != nil {
+				log.Errorf("孤块%x没有通过校验:%s", ob.block.Hash, err)
+				continue
+			}
+			log.Infof("孤块%x(高度%d)的上一个区块已到达,已加入区块链", ob.block.Hash, ob.block.Height)
+			queue = append(queue, ob.block.Hash)
+		}
+	}
+}
+
+//将区块存入孤块池,孤块池已满时丢弃最早失效的孤块
+func addOrphanBlock(block *Block) {
+	orphanLock.Lock()
+	defer orphanLock.Unlock()
+	if _, ok := orphanBlocks[string(block.Hash)]; ok {
+		return
+	}
+	expireOrphanBlocks()
+	if len(orphanBlocks) >= maxOrphanBlocks {
+		var oldest *orphanBlock
+		for _, ob := range orphanBlocks {
+			if oldest == nil || ob.expire < oldest.expire {
+				oldest = ob
+			}
+		}
+		removeOrphanBlock(oldest)
+	}
+	ob := &orphanBlock{block, time.Now().Unix() + orphanBlockExpiry}
+	orphanBlocks[string(block.Hash)] = ob
+	orphansByPrev[string(block.PreHash)] = append(orphansByPrev[string(block.PreHash)], ob)
+	log.Infof("区块%x的上一个区块%x尚未到达,暂存入孤块池", block.Hash, block.PreHash)
+}
+
+//取出以hash为上一个区块的全部孤块
+func takeOrphanBlocks(hash []byte) []*orphanBlock {
+	orphanLock.Lock()
+	defer orphanLock.Unlock()
+	obs := append([]*orphanBlock{}, orphansByPrev[string(hash)]...)
+	for _, ob := range obs {
+		removeOrphanBlock(ob)
+	}
+	return obs
+}
+
+func removeOrphanBlock(ob *orphanBlock) {
+	delete(orphanBlocks, string(ob.block.Hash))
+	prevKey := string(ob.block.PreHash)
+	siblings := orphansByPrev[prevKey]
+	for i := range siblings {
+		if siblings[i] == ob {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(orphansByPrev, prevKey)
+	} else {
+		orphansByPrev[prevKey] = siblings
+	}
+}
+
+//丢弃已失效的孤块
+func expireOrphanBlocks() {
+	now := time.Now().Unix()
+	for _, ob := range orphanBlocks {
+		if ob.expire < now {
+			log.Debugf("孤块%x已超时,从孤块池中丢弃", ob.block.Hash)
+			removeOrphanBlock(ob)
+		}
+	}
+}
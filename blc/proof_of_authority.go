@@ -0,0 +1,156 @@
+/*
+	权威证明(poa):只有配置的验证者地址可以出块,验证者按区块高度轮流出块,
+	轮到的验证者在距上一个区块poaTurnTimeoutFactor个期望出块间隔内没有出块时,依次顺延给下一个验证者,
+	避免一个验证者离线导致整条链停止出块;顺延出的区块时间戳不能超前本地时间,且计入的工作量少于轮到的验证者出的区块;
+	出块者使用私钥对区块hash进行签名,签名与出块者公钥保存在区块头中,不参与区块hash的计算
+*/
+package block
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/corgi-kx/logcustom"
+	"math/big"
+	"time"
+)
+
+//轮到的验证者超过多少个期望出块间隔没有出块时,由下一个验证者出块
+const poaTurnTimeoutFactor = 2
+
+//轮到的验证者出的区块与顺延出的区块计入的工作量,两者竞争时选择轮到的验证者出的区块
+const (
+	poaInTurnWork    = 2
+	poaOutOfTurnWork = 1
+)
+
+type poaEngine struct {
+	//有权出块的地址,按出块顺序排列
+	validators []string
+}
+
+func newPoaEngine(validators []string) (*poaEngine, error) {
+	if len(validators) == 0 {
+		return nil, errors.New("newPoaEngine err : poa共识至少需要一个验证者地址")
+	}
+	for _, v := range validators {
+		if !IsVaildBitcoinAddress(v) {
+			return nil, fmt.Errorf("newPoaEngine err : 验证者地址格式不正确:%s", v)
+		}
+	}
+	return &poaEngine{validators}, nil
+}
+
+func (p *poaEngine) Name() string {
+	return "poa"
+}
+
+//poa共识不需要难度值
+func (p *poaEngine) Prepare(bc *blockchain, header *BlockHeader) error {
+	header.Bits = 0
+	header.Nonce = 0
+	return nil
+}
+
+//使用本地钱包中轮到出块的验证者私钥对区块hash进行签名
+func (p *poaEngine) Seal(ctx context.Context, bc *blockchain, block *Block) error {
+	producer, err := p.producerOf(bc, &block.BlockHeader)
+	if err != nil {
+		return err
+	}
+	keys, ok := NewWallets(bc.BD).Wallets[producer]
+	if !ok {
+		return fmt.Errorf("高度%d应由验证者%s出块,本节点没有该地址的私钥", block.Height, producer)
+	}
+	block.Hash = block.CalcHash()
+	block.Signer = keys.PublicKey
+	block.Signature = ellipticCurveSign(keys.PrivateKey, block.Hash)
+	log.Infof("本节点已作为验证者%s签名出块,高度为:%d,区块hash为: %x", producer, block.Height, block.Hash)
+	return nil
+}
+
+//出块者由区块高度与距上一个区块的时间决定,签名者必须是该验证者
+func (p *poaEngine) VerifyHeader(bc *blockchain, header *BlockHeader) error {
+	err := p.VerifySeal(header)
+	if err != nil {
+		return err
+	}
+	producer, err := p.producerOf(bc, header)
+	if err != nil {
+		return err
+	}
+	if GetAddressFromPublicKey(header.Signer) != producer {
+		return fmt.Errorf("高度%d应由验证者%s出块", header.Height, producer)
+	}
+	//出块者可以自行调大时间戳抢占后面的出块权,顺延出的区块时间戳不能超前本地时间
+	if producer != p.producerAt(header.Height, 0) && header.TimeStamp > time.Now().Unix() {
+		return fmt.Errorf("顺延出的区块时间戳%d超前本地时间", header.TimeStamp)
+	}
+	return nil
+}
+
+//不依赖区块链状态的校验(用于孤块):签名者是验证者之一且签名正确
+func (p *poaEngine) VerifySeal(header *BlockHeader) error {
+	if header.Bits != 0 || header.Nonce != 0 {
+		return errors.New("poa区块的难度值与随机数必须为0")
+	}
+	if len(header.Signer) == 0 || len(header.Signature) == 0 {
+		return errors.New("区块没有出块者签名")
+	}
+	if !p.isValidator(GetAddressFromPublicKey(header.Signer)) {
+		return errors.New("出块者不是验证者")
+	}
+	if !ellipticCurveVerify(header.Signer, header.Signature, header.CalcHash()) {
+		return errors.New("出块者签名验证失败")
+	}
+	return nil
+}
+
+//验证者按区块高度轮流出块(创世区块由网络参数固定,不需要验证者生成),
+//bc不为空时按当前时间计算下一个区块的出块者,轮到的验证者超时没有出块时顺延给下一个验证者
+func (p *poaEngine) NextProducer(bc *blockchain, height int) string {
+	delay := int64(0)
+	if bc != nil && height == bc.GetLastBlockHeight()+1 {
+		delay = time.Now().Unix() - bc.GetLastBlockTime()
+	}
+	return p.producerAt(height, delay)
+}
+
+//获取区块头应有的出块者:由区块高度与区块时间戳距上一个区块的时间决定
+func (p *poaEngine) producerOf(bc *blockchain, header *BlockHeader) (string, error) {
+	parent := bc.getHeader(header.PreHash)
+	if parent == nil {
+		return "", errors.New("找不到上一个区块头")
+	}
+	return p.producerAt(header.Height, header.TimeStamp-parent.TimeStamp), nil
+}
+
+//高度为height、距上一个区块delay秒的区块的出块者:每超时一次,出块权顺延给下一个验证者
+func (p *poaEngine) producerAt(height int, delay int64) string {
+	if height < 1 {
+		return ""
+	}
+	turn := height - 1
+	if timeout := poaTurnTimeoutFactor * ActiveParams.TargetBlockTime; timeout > 0 && delay > 0 {
+		turn += int(delay / timeout)
+	}
+	return p.validators[turn%len(p.validators)]
+}
+
+//判断地址是否为验证者
+func (p *poaEngine) isValidator(address string) bool {
+	for _, v := range p.validators {
+		if v == address {
+			return true
+		}
+	}
+	return false
+}
+
+//轮到的验证者出的区块计入更多工作量,分叉选择在长度相同时优先选择按顺序出块的分支
+func (p *poaEngine) CalcWork(header *BlockHeader) *big.Int {
+	if len(header.Signer) != 0 && GetAddressFromPublicKey(header.Signer) == p.producerAt(header.Height, 0) {
+		return big.NewInt(poaInTurnWork)
+	}
+	return big.NewInt(poaOutOfTurnWork)
+}
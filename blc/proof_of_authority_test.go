@@ -0,0 +1,132 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func newTestKeys(t *testing.T) *bitcoinKeys {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//公钥坐标补齐到32字节,避免签名校验时拆分公钥出错
+	x := paddedAppend(32, []byte{}, privKey.PublicKey.X.Bytes())
+	y := paddedAppend(32, []byte{}, privKey.PublicKey.Y.Bytes())
+	return &bitcoinKeys{privKey, append(x, y...), nil}
+}
+
+//使用keys对区块头进行签名
+func signTestHeader(keys *bitcoinKeys, header *BlockHeader) {
+	header.Signer = keys.PublicKey
+	header.Signature = ellipticCurveSign(keys.PrivateKey, header.CalcHash())
+}
+
+func TestPoaVerifySeal(t *testing.T) {
+	t.Log("测试poa共识中验证者轮流出块与出块者签名校验")
+	{
+		a, b := newTestKeys(t), newTestKeys(t)
+		poa, err := newPoaEngine([]string{string(a.getAddress()), string(b.getAddress())})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if poa.NextProducer(nil, 1) != string(a.getAddress()) || poa.NextProducer(nil, 4) != string(b.getAddress()) {
+			t.Fatalf("\t出块者轮换顺序不正确")
+		}
+		header := &BlockHeader{blockVersion, make([]byte, 32), []byte("root"), 1, 0, 0, 1, nil, nil}
+		signTestHeader(a, header)
+		if err := poa.VerifySeal(header); err != nil {
+			t.Fatalf("\t轮到出块的验证者签名应通过校验：%s", err)
+		}
+		//签名不参与区块hash的计算,但篡改区块头后签名失效
+		header.TimeStamp = 2
+		if poa.VerifySeal(header) == nil {
+			t.Fatalf("\t篡改区块头后签名不应通过校验")
+		}
+		//不是验证者的地址签名
+		signTestHeader(newTestKeys(t), header)
+		if poa.VerifySeal(header) == nil {
+			t.Fatalf("\t不是验证者的地址签名不应通过校验")
+		}
+		t.Log("\tpoa出块者校验正确")
+	}
+}
+
+func TestPoaTurnTimeout(t *testing.T) {
+	t.Log("测试poa共识中轮到的验证者超时没有出块时由下一个验证者出块")
+	{
+		a, b, c := newTestKeys(t), newTestKeys(t), newTestKeys(t)
+		poa, err := newPoaEngine([]string{string(a.getAddress()), string(b.getAddress()), string(c.getAddress())})
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout := poaTurnTimeoutFactor * ActiveParams.TargetBlockTime
+		if poa.producerAt(2, timeout-1) != string(b.getAddress()) {
+			t.Fatalf("\t未超时时应由轮到的验证者出块")
+		}
+		if poa.producerAt(2, timeout) != string(c.getAddress()) {
+			t.Fatalf("\t超时后应由下一个验证者出块")
+		}
+		if poa.producerAt(2, timeout*2) != string(a.getAddress()) {
+			t.Fatalf("\t再次超时后应继续顺延给下一个验证者")
+		}
+		//时间戳早于上一个区块时不能改变出块者
+		if poa.producerAt(2, -timeout) != string(b.getAddress()) {
+			t.Fatalf("\t时间戳早于上一个区块时出块者不正确")
+		}
+		t.Log("\t出块权顺延正确")
+	}
+}
+
+func TestPoaOutOfTurnBlock(t *testing.T) {
+	t.Log("测试顺延出的区块时间戳不能超前本地时间,且工作量少于轮到的验证者出的区块")
+	{
+		bc := newTestChain(t)
+		keys := []*bitcoinKeys{newTestKeys(t), newTestKeys(t), newTestKeys(t)}
+		validators := []string{}
+		for _, k := range keys {
+			validators = append(validators, string(k.getAddress()))
+		}
+		poa, err := newPoaEngine(validators)
+		if err != nil {
+			t.Fatal(err)
+		}
+		genesis := ActiveParams.GenesisBlock()
+		//找到一个由顺延的验证者出块的时间戳
+		newHeader := func(timeStamp int64) *BlockHeader {
+			for {
+				producer := poa.producerAt(2, timeStamp-genesis.TimeStamp)
+				if producer != validators[1] {
+					header := &BlockHeader{blockVersion, genesis.Hash, []byte("root"), timeStamp, 0, 0, 2, nil, nil}
+					for i, v := range validators {
+						if v == producer {
+							signTestHeader(keys[i], header)
+						}
+					}
+					return header
+				}
+				timeStamp++
+			}
+		}
+		future := newHeader(time.Now().Unix() + 600)
+		if poa.VerifyHeader(bc, future) == nil {
+			t.Fatalf("\t时间戳超前本地时间的顺延区块通过了校验")
+		}
+		past := newHeader(time.Now().Unix() - 600)
+		if err := poa.VerifyHeader(bc, past); err != nil {
+			t.Fatalf("\t顺延区块没有通过校验:%s", err)
+		}
+		inTurn := &BlockHeader{blockVersion, genesis.Hash, []byte("root"), genesis.TimeStamp + 1, 0, 0, 2, nil, nil}
+		signTestHeader(keys[1], inTurn)
+		if err := poa.VerifyHeader(bc, inTurn); err != nil {
+			t.Fatalf("\t轮到的验证者出的区块没有通过校验:%s", err)
+		}
+		if poa.CalcWork(inTurn).Cmp(poa.CalcWork(past)) <= 0 {
+			t.Fatalf("\t轮到的验证者出的区块工作量应大于顺延出的区块")
+		}
+		t.Log("\t顺延出块校验正确")
+	}
+}
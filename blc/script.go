@@ -0,0 +1,742 @@
+/*
+	交易脚本:交易输出通过锁定脚本(ScriptPubKey)规定花费条件,交易输入通过解锁脚本(ScriptSig)提供花费所需的数据,
+	校验时先执行解锁脚本,再在同一个栈上执行所花费输出的锁定脚本,执行完成后栈顶元素为真则允许花费
+	操作码的编号与含义和比特币脚本一致,只实现了其中的一个子集:数据压栈、流程控制、栈操作、hash、签名验证与时间锁
+	标准的支付到公钥hash(P2PKH)锁定脚本为: OP_DUP OP_HASH160 <公钥hash> OP_EQUALVERIFY OP_CHECKSIG
+	对应的解锁脚本为: <签名> <公钥>
+	支付到脚本hash(P2SH)的锁定脚本为: OP_HASH160 <赎回脚本hash> OP_EQUAL,解锁脚本的最后一项为赎回脚本,
+	锁定脚本验证通过后,再以解锁脚本中的其余数据执行赎回脚本,多重签名地址即为m-of-n多重签名赎回脚本的P2SH地址
+*/
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//操作码
+const (
+	OP_0                   = 0x00
+	OP_PUSHDATA1           = 0x4c
+	OP_PUSHDATA2           = 0x4d
+	OP_PUSHDATA4           = 0x4e
+	OP_1NEGATE             = 0x4f
+	OP_1                   = 0x51
+	OP_16                  = 0x60
+	OP_NOP                 = 0x61
+	OP_IF                  = 0x63
+	OP_NOTIF               = 0x64
+	OP_ELSE                = 0x67
+	OP_ENDIF               = 0x68
+	OP_VERIFY              = 0x69
+	OP_RETURN              = 0x6a
+	OP_DROP                = 0x75
+	OP_DUP                 = 0x76
+	OP_SWAP                = 0x7c
+	OP_SIZE                = 0x82
+	OP_EQUAL               = 0x87
+	OP_EQUALVERIFY         = 0x88
+	OP_SHA256              = 0xa8
+	OP_HASH160             = 0xa9
+	OP_HASH256             = 0xaa
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKMULTISIGVERIFY = 0xaf
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+	OP_CHECKSEQUENCEVERIFY = 0xb2
+)
+
+var opcodeNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_PUSHDATA4:           "OP_PUSHDATA4",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_NOP:                 "OP_NOP",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_SIZE:                "OP_SIZE",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_SHA256:              "OP_SHA256",
+	OP_HASH160:             "OP_HASH160",
+	OP_HASH256:             "OP_HASH256",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+	OP_CHECKSEQUENCEVERIFY: "OP_CHECKSEQUENCEVERIFY",
+}
+
+//脚本的各项限制,与比特币一致
+const (
+	maxScriptSize         = 10000
+	maxScriptElementSize  = 520
+	maxStackSize          = 1000
+	maxOpsPerScript       = 201
+	maxPubKeysPerMultiSig = 20
+)
+
+//解析后的一条脚本指令,压栈指令带有要压入的数据
+type parsedOp struct {
+	opcode byte
+	data   []byte
+}
+
+//是否为数据压栈指令(包括OP_1NEGATE与OP_1~OP_16)
+func (p parsedOp) isPush() bool {
+	return p.opcode <= OP_16 && p.opcode != 0x50
+}
+
+//将脚本解析为指令序列
+func parseScript(script []byte) ([]parsedOp, error) {
+	ops := []parsedOp{}
+	for i := 0; i < len(script); {
+		opcode := script[i]
+		i++
+		var size int
+		switch {
+		case opcode > OP_0 && opcode < OP_PUSHDATA1:
+			size = int(opcode)
+		case opcode == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, errors.New("脚本在OP_PUSHDATA1处被截断")
+			}
+			size = int(script[i])
+			i++
+		case opcode == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, errors.New("脚本在OP_PUSHDATA2处被截断")
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case opcode == OP_PUSHDATA4:
+			if i+4 > len(script) {
+				return nil, errors.New("脚本在OP_PUSHDATA4处被截断")
+			}
+			size = int(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		default:
+			ops = append(ops, parsedOp{opcode, nil})
+			continue
+		}
+		if size < 0 || i+size > len(script) {
+			return nil, errors.New("脚本中压栈的数据被截断")
+		}
+		ops = append(ops, parsedOp{opcode, script[i : i+size]})
+		i += size
+	}
+	return ops, nil
+}
+
+//生成将数据压栈的脚本片段,使用最短的压栈方式
+func pushData(data []byte) []byte {
+	size := len(data)
+	switch {
+	case size == 0:
+		return []byte{OP_0}
+	case size == 1 && data[0] >= 1 && data[0] <= 16:
+		return []byte{OP_1 - 1 + data[0]}
+	case size < OP_PUSHDATA1:
+		return append([]byte{byte(size)}, data...)
+	case size <= 0xff:
+		return append([]byte{OP_PUSHDATA1, byte(size)}, data...)
+	case size <= 0xffff:
+		b := []byte{OP_PUSHDATA2, 0, 0}
+		binary.LittleEndian.PutUint16(b[1:], uint16(size))
+		return append(b, data...)
+	default:
+		b := []byte{OP_PUSHDATA4, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(b[1:], uint32(size))
+		return append(b, data...)
+	}
+}
+
+//生成将整数压栈的脚本片段
+func pushInt(n int64) []byte {
+	if n == -1 {
+		return []byte{OP_1NEGATE}
+	}
+	return pushData(scriptNumBytes(n))
+}
+
+//整数的脚本编码:小端,最高字节的最高位为符号位,使用最短的编码,0编码为空字节数组
+func scriptNumBytes(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+	b := []byte{}
+	for abs > 0 {
+		b = append(b, byte(abs&0xff))
+		abs >>= 8
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		b = append(b, extra)
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+//将栈中的元素解析为整数,maxLen为允许的最大字节数,要求使用最短编码
+func scriptNum(b []byte, maxLen int) (int64, error) {
+	if len(b) > maxLen {
+		return 0, fmt.Errorf("整数长度%d超过了%d字节", len(b), maxLen)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if b[len(b)-1]&0x7f == 0 && (len(b) == 1 || b[len(b)-2]&0x80 == 0) {
+		return 0, errors.New("整数没有使用最短编码")
+	}
+	var n int64
+	for i := range b {
+		n |= int64(b[i]) << uint(8*i)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(b)-1)))
+		return -n, nil
+	}
+	return n, nil
+}
+
+//栈中元素转换为布尔值:全为0(包括负0)为假,否则为真
+func castToBool(b []byte) bool {
+	for i := range b {
+		if b[i] != 0 {
+			return !(i == len(b)-1 && b[i] == 0x80)
+		}
+	}
+	return false
+}
+
+//生成支付到公钥hash(P2PKH)的标准锁定脚本
+func NewP2PKHScript(publicKeyHash []byte) []byte {
+	script := []byte{OP_DUP, OP_HASH160}
+	script = append(script, pushData(publicKeyHash)...)
+	return append(script, OP_EQUALVERIFY, OP_CHECKSIG)
+}
+
+//生成花费P2PKH输出的解锁脚本
+func newP2PKHSigScript(signature, publicKey []byte) []byte {
+	return append(pushData(signature), pushData(publicKey)...)
+}
+
+//从P2PKH锁定脚本中取出公钥hash,不是P2PKH脚本时返回nil
+func extractPublicKeyHash(script []byte) []byte {
+	if len(script) == 25 && script[0] == OP_DUP && script[1] == OP_HASH160 && script[2] == 20 &&
+		script[23] == OP_EQUALVERIFY && script[24] == OP_CHECKSIG {
+		return script[3:23]
+	}
+	return nil
+}
+
+//生成支付到脚本hash(P2SH)的锁定脚本
+func NewP2SHScript(scriptHash []byte) []byte {
+	script := []byte{OP_HASH160}
+	script = append(script, pushData(scriptHash)...)
+	return append(script, OP_EQUAL)
+}
+
+//从P2SH锁定脚本中取出赎回脚本hash,不是P2SH脚本时返回nil
+func extractScriptHash(script []byte) []byte {
+	if len(script) == 23 && script[0] == OP_HASH160 && script[1] == 20 && script[22] == OP_EQUAL {
+		return script[2:22]
+	}
+	return nil
+}
+
+//生成m-of-n多重签名的赎回脚本: m <公钥1>...<公钥n> n OP_CHECKMULTISIG
+func NewMultiSigScript(m int, publicKeys [][]byte) ([]byte, error) {
+	n := len(publicKeys)
+	if n == 0 || n > 16 || m < 1 || m > n {
+		return nil, fmt.Errorf("多重签名需要1~16个公钥且1<=m<=n,当前m=%d,n=%d", m, n)
+	}
+	script := pushInt(int64(m))
+	for _, publicKey := range publicKeys {
+		if len(publicKey) == 0 {
+			return nil, errors.New("多重签名的公钥不能为空")
+		}
+		script = append(script, pushData(publicKey)...)
+	}
+	script = append(append(script, pushInt(int64(n))...), OP_CHECKMULTISIG)
+	//赎回脚本在解锁脚本中作为一项数据压栈,长度受单项数据的限制
+	if len(script) > maxScriptElementSize {
+		return nil, fmt.Errorf("赎回脚本长度%d超过了%d字节,请减少公钥数量", len(script), maxScriptElementSize)
+	}
+	return script, nil
+}
+
+//解析m-of-n多重签名赎回脚本,返回m与公钥列表
+func parseMultiSigScript(script []byte) (int, [][]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(ops) < 4 || ops[len(ops)-1].opcode != OP_CHECKMULTISIG {
+		return 0, nil, errors.New("不是多重签名脚本")
+	}
+	smallInt := func(op parsedOp) int {
+		if op.opcode >= OP_1 && op.opcode <= OP_16 {
+			return int(op.opcode-OP_1) + 1
+		}
+		return -1
+	}
+	m, n := smallInt(ops[0]), smallInt(ops[len(ops)-2])
+	publicKeys := [][]byte{}
+	for _, op := range ops[1 : len(ops)-2] {
+		if !op.isPush() || len(op.data) == 0 {
+			return 0, nil, errors.New("多重签名脚本中的公钥格式不正确")
+		}
+		publicKeys = append(publicKeys, op.data)
+	}
+	if m < 1 || n != len(publicKeys) || m > n {
+		return 0, nil, errors.New("多重签名脚本中的m或n不正确")
+	}
+	return m, publicKeys, nil
+}
+
+//从P2PKH解锁脚本中取出公钥,不是<签名> <公钥>形式时返回nil
+func extractSigScriptPublicKey(script []byte) []byte {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 2 || !ops[0].isPush() || !ops[1].isPush() {
+		return nil
+	}
+	return ops[1].data
+}
+
+//将脚本转换为可读的文本形式
+func DisasmScript(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return fmt.Sprintf("[脚本解析失败:%s] %x", err, script)
+	}
+	words := []string{}
+	for _, op := range ops {
+		switch {
+		case op.opcode == OP_0:
+			words = append(words, "OP_0")
+		case op.opcode >= OP_1 && op.opcode <= OP_16:
+			words = append(words, fmt.Sprintf("OP_%d", op.opcode-OP_1+1))
+		case op.isPush() && op.opcode != OP_1NEGATE:
+			words = append(words, hex.EncodeToString(op.data))
+		default:
+			if name, ok := opcodeNames[op.opcode]; ok {
+				words = append(words, name)
+			} else {
+				words = append(words, fmt.Sprintf("OP_UNKNOWN(0x%02x)", op.opcode))
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+//脚本执行环境:正在校验的交易与输入,以及签名hash使用的脚本(所花费输出的锁定脚本或P2SH的赎回脚本)
+type scriptEngine struct {
+	tx         *Transaction
+	index      int
+	scriptCode []byte
+	stack      [][]byte
+	//流程控制栈,记录当前所在的每一层OP_IF分支是否执行
+	condStack []bool
+	ops       int
+}
+
+//校验交易第index个输入的解锁脚本与所花费输出的锁定脚本
+func verifyScript(tx *Transaction, index int, prevOut TXOutput) error {
+	scriptSig := tx.Vint[index].ScriptSig
+	if len(scriptSig) > maxScriptSize || len(prevOut.ScriptPubKey) > maxScriptSize {
+		return errors.New("脚本长度超过限制")
+	}
+	sigOps, err := parseScript(scriptSig)
+	if err != nil {
+		return err
+	}
+	//解锁脚本只能压入数据,防止交易在传播过程中被他人修改解锁脚本
+	for _, op := range sigOps {
+		if !op.isPush() {
+			return errors.New("解锁脚本只能包含数据压栈操作")
+		}
+	}
+	pkOps, err := parseScript(prevOut.ScriptPubKey)
+	if err != nil {
+		return err
+	}
+	e := &scriptEngine{tx, index, prevOut.ScriptPubKey, [][]byte{}, []bool{}, 0}
+	if err := e.execute(sigOps); err != nil {
+		return fmt.Errorf("解锁脚本执行失败:%s", err)
+	}
+	//P2SH需要在锁定脚本验证赎回脚本hash之后,用解锁脚本留下的数据执行赎回脚本
+	p2sh := extractScriptHash(prevOut.ScriptPubKey) != nil
+	sigStack := append([][]byte{}, e.stack...)
+	if err := e.execute(pkOps); err != nil {
+		return fmt.Errorf("锁定脚本执行失败:%s", err)
+	}
+	if len(e.stack) == 0 || !castToBool(e.stack[len(e.stack)-1]) {
+		return errors.New("脚本执行完成后栈顶不为真")
+	}
+	if !p2sh {
+		return nil
+	}
+	e.stack = sigStack
+	redeemScript, err := e.pop()
+	if err != nil {
+		return err
+	}
+	redeemOps, err := parseScript(redeemScript)
+	if err != nil {
+		return err
+	}
+	e.scriptCode = redeemScript
+	if err := e.execute(redeemOps); err != nil {
+		return fmt.Errorf("赎回脚本执行失败:%s", err)
+	}
+	if len(e.stack) == 0 || !castToBool(e.stack[len(e.stack)-1]) {
+		return errors.New("赎回脚本执行完成后栈顶不为真")
+	}
+	return nil
+}
+
+//执行一段脚本
+func (e *scriptEngine) execute(ops []parsedOp) error {
+	e.condStack = e.condStack[:0]
+	e.ops = 0
+	for _, op := range ops {
+		if err := e.step(op); err != nil {
+			return err
+		}
+		if len(e.stack) > maxStackSize {
+			return errors.New("栈中元素数量超过限制")
+		}
+	}
+	if len(e.condStack) != 0 {
+		return errors.New("OP_IF没有对应的OP_ENDIF")
+	}
+	return nil
+}
+
+//当前是否处于需要执行的分支中
+func (e *scriptEngine) executing() bool {
+	for _, c := range e.condStack {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *scriptEngine) push(b []byte) {
+	e.stack = append(e.stack, b)
+}
+
+func (e *scriptEngine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("栈为空")
+	}
+	b := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return b, nil
+}
+
+func (e *scriptEngine) popInt() (int64, error) {
+	b, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return scriptNum(b, 4)
+}
+
+func (e *scriptEngine) popBool() (bool, error) {
+	b, err := e.pop()
+	if err != nil {
+		return false, err
+	}
+	return castToBool(b), nil
+}
+
+func boolBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+//执行一条指令
+func (e *scriptEngine) step(op parsedOp) error {
+	if len(op.data) > maxScriptElementSize {
+		return errors.New("压栈的数据长度超过限制")
+	}
+	if op.opcode > OP_16 {
+		e.ops++
+		if e.ops > maxOpsPerScript {
+			return errors.New("操作码数量超过限制")
+		}
+	}
+	executing := e.executing()
+	//流程控制指令在不执行的分支中也需要处理,以便找到对应的OP_ELSE与OP_ENDIF
+	switch op.opcode {
+	case OP_IF, OP_NOTIF:
+		cond := false
+		if executing {
+			v, err := e.popBool()
+			if err != nil {
+				return err
+			}
+			cond = v == (op.opcode == OP_IF)
+		}
+		e.condStack = append(e.condStack, cond)
+		return nil
+	case OP_ELSE:
+		if len(e.condStack) == 0 {
+			return errors.New("OP_ELSE没有对应的OP_IF")
+		}
+		e.condStack[len(e.condStack)-1] = !e.condStack[len(e.condStack)-1]
+		return nil
+	case OP_ENDIF:
+		if len(e.condStack) == 0 {
+			return errors.New("OP_ENDIF没有对应的OP_IF")
+		}
+		e.condStack = e.condStack[:len(e.condStack)-1]
+		return nil
+	}
+	if !executing {
+		return nil
+	}
+	switch {
+	case op.opcode == OP_1NEGATE:
+		e.push(scriptNumBytes(-1))
+		return nil
+	case op.opcode >= OP_1 && op.opcode <= OP_16:
+		e.push(scriptNumBytes(int64(op.opcode - OP_1 + 1)))
+		return nil
+	case op.isPush():
+		e.push(op.data)
+		return nil
+	}
+	switch op.opcode {
+	case OP_NOP:
+	case OP_VERIFY:
+		v, err := e.popBool()
+		if err != nil {
+			return err
+		}
+		if !v {
+			return errors.New("OP_VERIFY校验失败")
+		}
+	case OP_RETURN:
+		return errors.New("执行到了OP_RETURN")
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		if len(e.stack) < 1 {
+			return errors.New("OP_DUP时栈为空")
+		}
+		e.push(e.stack[len(e.stack)-1])
+	case OP_SWAP:
+		if len(e.stack) < 2 {
+			return errors.New("OP_SWAP时栈中元素不足")
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+	case OP_SIZE:
+		if len(e.stack) < 1 {
+			return errors.New("OP_SIZE时栈为空")
+		}
+		e.push(scriptNumBytes(int64(len(e.stack[len(e.stack)-1]))))
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if op.opcode == OP_EQUALVERIFY {
+			if !bytes.Equal(a, b) {
+				return errors.New("OP_EQUALVERIFY校验失败")
+			}
+			return nil
+		}
+		e.push(boolBytes(bytes.Equal(a, b)))
+	case OP_SHA256, OP_HASH160, OP_HASH256:
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(b)
+		switch op.opcode {
+		case OP_SHA256:
+			e.push(hash[:])
+		case OP_HASH160:
+			e.push(generatePublicKeyHash(b))
+		case OP_HASH256:
+			hash = sha256.Sum256(hash[:])
+			e.push(hash[:])
+		}
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		publicKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		ok := e.checkSig(signature, publicKey)
+		if op.opcode == OP_CHECKSIGVERIFY {
+			if !ok {
+				return errors.New("OP_CHECKSIGVERIFY签名验证失败")
+			}
+			return nil
+		}
+		e.push(boolBytes(ok))
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		ok, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if op.opcode == OP_CHECKMULTISIGVERIFY {
+			if !ok {
+				return errors.New("OP_CHECKMULTISIGVERIFY签名验证失败")
+			}
+			return nil
+		}
+		e.push(boolBytes(ok))
+	case OP_CHECKLOCKTIMEVERIFY:
+		return e.checkLockTime()
+	case OP_CHECKSEQUENCEVERIFY:
+		return e.checkSequence()
+	default:
+		return fmt.Errorf("不支持的操作码0x%02x", op.opcode)
+	}
+	return nil
+}
+
+//验证签名:签名的内容为交易的签名hash,其中被花费输入的解锁脚本替换为所花费输出的锁定脚本(P2SH时为赎回脚本)
+func (e *scriptEngine) checkSig(signature, publicKey []byte) bool {
+	if len(signature) == 0 || len(publicKey) == 0 {
+		return false
+	}
+	return ellipticCurveVerify(publicKey, signature, e.tx.signatureHash(e.index, e.scriptCode))
+}
+
+//多重签名验证,栈中的数据依次为: <占位元素> <签名1>...<签名m> m <公钥1>...<公钥n> n
+//签名必须按公钥的顺序排列,占位元素沿用比特币的实现,必须为空
+func (e *scriptEngine) checkMultiSig() (bool, error) {
+	n, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > maxPubKeysPerMultiSig {
+		return false, fmt.Errorf("多重签名的公钥数量%d不正确", n)
+	}
+	e.ops += int(n)
+	if e.ops > maxOpsPerScript {
+		return false, errors.New("操作码数量超过限制")
+	}
+	publicKeys := make([][]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		if publicKeys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	m, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, fmt.Errorf("多重签名的签名数量%d不正确", m)
+	}
+	signatures := make([][]byte, m)
+	for i := int(m) - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	dummy, err := e.pop()
+	if err != nil {
+		return false, err
+	}
+	if len(dummy) != 0 {
+		return false, errors.New("多重签名的占位元素必须为空")
+	}
+	//依次为每个签名寻找匹配的公钥,剩余的公钥不足以匹配剩余的签名时失败
+	k := 0
+	for _, signature := range signatures {
+		for k < len(publicKeys) && !e.checkSig(signature, publicKeys[k]) {
+			k++
+		}
+		if k == len(publicKeys) {
+			return false, nil
+		}
+		k++
+	}
+	return true, nil
+}
+
+//绝对时间锁:栈顶的值小于lockTimeThreshold时为区块高度,否则为unix时间戳,
+//交易的LockTime必须与其类型相同且不小于该值,再由交易的绝对时间锁保证交易被打包时已过该高度或时间
+//与比特币一致,执行后不弹出栈顶元素
+func (e *scriptEngine) checkLockTime() error {
+	if len(e.stack) < 1 {
+		return errors.New("OP_CHECKLOCKTIMEVERIFY时栈为空")
+	}
+	lockTime, err := scriptNum(e.stack[len(e.stack)-1], 5)
+	if err != nil {
+		return err
+	}
+	if lockTime < 0 {
+		return errors.New("时间锁不能为负数")
+	}
+	if (lockTime < lockTimeThreshold) != (e.tx.LockTime < lockTimeThreshold) {
+		return errors.New("时间锁与交易LockTime的类型(高度或时间)不一致")
+	}
+	if e.tx.LockTime < lockTime {
+		return fmt.Errorf("交易的LockTime %d小于时间锁%d", e.tx.LockTime, lockTime)
+	}
+	return nil
+}
+
+//相对时间锁:栈顶的值为区块数量,输入的相对时间锁必须不小于该值,再由输入的相对时间锁保证所花费的输出已被打包足够多的区块
+//执行后不弹出栈顶元素
+func (e *scriptEngine) checkSequence() error {
+	if len(e.stack) < 1 {
+		return errors.New("OP_CHECKSEQUENCEVERIFY时栈为空")
+	}
+	sequence, err := scriptNum(e.stack[len(e.stack)-1], 5)
+	if err != nil {
+		return err
+	}
+	if sequence < 0 {
+		return errors.New("相对时间锁不能为负数")
+	}
+	if int64(e.tx.Vint[e.index].RelativeLock()) < sequence {
+		return fmt.Errorf("输入的相对时间锁%d小于脚本中的相对时间锁%d", e.tx.Vint[e.index].RelativeLock(), sequence)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package block
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+//构造一笔花费prevOut的交易
+func newTestSpend(prevOut TXOutput) *Transaction {
+	ts := &Transaction{nil, []TXInput{{[]byte("prev"), 0, nil, 0}}, []TXOutput{{prevOut.Value, []byte("to")}}, 0}
+	ts.hash()
+	return ts
+}
+
+//使用keys对交易的第0个输入进行签名
+func signTestSpend(keys *bitcoinKeys, ts *Transaction, prevOut TXOutput) []byte {
+	return ellipticCurveSign(keys.PrivateKey, ts.signatureHash(0, prevOut.ScriptPubKey))
+}
+
+func TestP2PKHScript(t *testing.T) {
+	t.Log("测试P2PKH锁定脚本的签名验证")
+	{
+		a, b := newTestKeys(t), newTestKeys(t)
+		prevOut := TXOutput{10, NewP2PKHScript(generatePublicKeyHash(a.PublicKey))}
+		ts := newTestSpend(prevOut)
+		ts.Vint[0].ScriptSig = newP2PKHSigScript(signTestSpend(a, ts, prevOut), a.PublicKey)
+		if err := ts.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\t正确的签名没有通过脚本验证:%s", err)
+		}
+		if string(GetAddressFromPublicKeyHash(prevOut.PublicKeyHash())) != string(a.getAddress()) {
+			t.Fatalf("\t从锁定脚本中取出的地址不正确")
+		}
+		//其他人的公钥与签名不能花费
+		ts.Vint[0].ScriptSig = newP2PKHSigScript(signTestSpend(b, ts, prevOut), b.PublicKey)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t其他人的签名通过了脚本验证")
+		}
+		//修改输出后原签名失效
+		ts.Vint[0].ScriptSig = newP2PKHSigScript(signTestSpend(a, ts, prevOut), a.PublicKey)
+		ts.Vout[0].Value = 9
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t修改输出后签名仍然通过了脚本验证")
+		}
+		//解锁脚本中不能包含操作码
+		ts.Vint[0].ScriptSig = append(ts.Vint[0].ScriptSig, OP_DROP)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t带有操作码的解锁脚本通过了脚本验证")
+		}
+		t.Log("\tP2PKH脚本验证正确")
+	}
+}
+
+func TestMultiSigScript(t *testing.T) {
+	t.Log("测试2-of-3多重签名脚本")
+	{
+		keys := []*bitcoinKeys{newTestKeys(t), newTestKeys(t), newTestKeys(t)}
+		script := pushInt(2)
+		for _, k := range keys {
+			script = append(script, pushData(k.PublicKey)...)
+		}
+		script = append(append(script, pushInt(3)...), OP_CHECKMULTISIG)
+		prevOut := TXOutput{10, script}
+		ts := newTestSpend(prevOut)
+		sig0, sig1, sig2 := signTestSpend(keys[0], ts, prevOut), signTestSpend(keys[1], ts, prevOut), signTestSpend(keys[2], ts, prevOut)
+		ts.Vint[0].ScriptSig = append(append([]byte{OP_0}, pushData(sig0)...), pushData(sig2)...)
+		if err := ts.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\t两个正确的签名没有通过脚本验证:%s", err)
+		}
+		//签名顺序与公钥顺序不一致
+		ts.Vint[0].ScriptSig = append(append([]byte{OP_0}, pushData(sig2)...), pushData(sig1)...)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t顺序错误的签名通过了脚本验证")
+		}
+		//签名数量不足
+		ts.Vint[0].ScriptSig = append(append([]byte{OP_0}, pushData(sig1)...), OP_0)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t只有一个签名时通过了脚本验证")
+		}
+		t.Log("\t多重签名脚本验证正确")
+	}
+}
+
+func TestHashTimeLockScript(t *testing.T) {
+	t.Log("测试hash锁与时间锁:知道原像可以立即花费,否则在高度100之后由退款方花费")
+	{
+		refund := newTestKeys(t)
+		preimage := []byte("secret")
+		hash := sha256.Sum256(preimage)
+		//OP_IF OP_SHA256 <hash> OP_EQUAL OP_ELSE 100 OP_CHECKLOCKTIMEVERIFY OP_DROP <公钥> OP_CHECKSIG OP_ENDIF
+		script := append([]byte{OP_IF, OP_SHA256}, pushData(hash[:])...)
+		script = append(append(script, OP_EQUAL, OP_ELSE), pushInt(100)...)
+		script = append(append(script, OP_CHECKLOCKTIMEVERIFY, OP_DROP), pushData(refund.PublicKey)...)
+		script = append(script, OP_CHECKSIG, OP_ENDIF)
+		prevOut := TXOutput{10, script}
+		ts := newTestSpend(prevOut)
+		ts.Vint[0].ScriptSig = append(pushData(preimage), OP_1)
+		if err := ts.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\t正确的原像没有通过脚本验证:%s", err)
+		}
+		ts.Vint[0].ScriptSig = append(pushData([]byte("wrong")), OP_1)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t错误的原像通过了脚本验证")
+		}
+		//LockTime参与签名,修改后需要重新签名
+		ts.LockTime = 99
+		ts.Vint[0].ScriptSig = append(pushData(signTestSpend(refund, ts, prevOut)), OP_0)
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t时间锁到期之前退款通过了脚本验证")
+		}
+		ts.LockTime = 100
+		ts.Vint[0].ScriptSig = append(pushData(signTestSpend(refund, ts, prevOut)), OP_0)
+		if err := ts.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\t时间锁到期之后退款没有通过脚本验证:%s", err)
+		}
+		t.Log("\thash锁与时间锁验证正确")
+	}
+}
+
+func TestCheckSequenceScript(t *testing.T) {
+	t.Log("测试相对时间锁:输入的Sequence不小于脚本中的区块数量才能花费")
+	{
+		owner := newTestKeys(t)
+		//10 OP_CHECKSEQUENCEVERIFY OP_DROP <公钥> OP_CHECKSIG
+		script := append(pushInt(10), OP_CHECKSEQUENCEVERIFY, OP_DROP)
+		script = append(append(script, pushData(owner.PublicKey)...), OP_CHECKSIG)
+		prevOut := TXOutput{10, script}
+		ts := newTestSpend(prevOut)
+		ts.Vint[0].Sequence = 9
+		ts.Vint[0].ScriptSig = pushData(signTestSpend(owner, ts, prevOut))
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\tSequence小于相对时间锁时通过了脚本验证")
+		}
+		ts.Vint[0].Sequence = 10
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\t修改Sequence后原签名仍然有效")
+		}
+		ts.Vint[0].ScriptSig = pushData(signTestSpend(owner, ts, prevOut))
+		if err := ts.verifyInputScript(0, prevOut); err != nil {
+			t.Fatalf("\tSequence满足相对时间锁时没有通过脚本验证:%s", err)
+		}
+		//可替换标志位不影响相对时间锁
+		ts.Vint[0].Sequence = 10 | SequenceReplaceable
+		ts.Vint[0].ScriptSig = pushData(signTestSpend(owner, ts, prevOut))
+		if err := ts.verifyInputScript(0, prevOut); err != nil || !ts.IsReplaceable() {
+			t.Fatalf("\t设置可替换标志后相对时间锁验证不正确:%v", err)
+		}
+		t.Log("\t相对时间锁验证正确")
+	}
+}
+
+func TestNullDataScript(t *testing.T) {
+	t.Log("测试OP_RETURN输出不能被花费")
+	{
+		prevOut := TXOutput{0, append([]byte{OP_RETURN}, pushData([]byte("data"))...)}
+		ts := newTestSpend(prevOut)
+		ts.Vint[0].ScriptSig = []byte{OP_1}
+		if ts.verifyInputScript(0, prevOut) == nil {
+			t.Fatalf("\tOP_RETURN输出被花费")
+		}
+		if DisasmScript(prevOut.ScriptPubKey) != "OP_RETURN 64617461" {
+			t.Fatalf("\t脚本反汇编结果不正确:%s", DisasmScript(prevOut.ScriptPubKey))
+		}
+		t.Log("\tOP_RETURN输出不能被花费")
+	}
+}
+
+func TestScriptNum(t *testing.T) {
+	t.Log("测试脚本整数编码")
+	{
+		for _, n := range []int64{0, 1, -1, 16, 127, 128, -128, 255, 256, 500000000, -2147483647} {
+			v, err := scriptNum(scriptNumBytes(n), 5)
+			if err != nil || v != n {
+				t.Fatalf("\t整数%d编码后解析为%d:%v", n, v, err)
+			}
+		}
+		if _, err := scriptNum([]byte{1, 0}, 4); err == nil {
+			t.Fatalf("\t非最短编码的整数解析成功")
+		}
+		t.Log("\t脚本整数编码正确")
+	}
+}
@@ -0,0 +1,83 @@
+/*
+	出块奖励:每隔HalvingInterval个区块奖励减半,挖矿奖励的总量不超过MaxTokenSupply;
+	奖励交易的输出需要经过CoinbaseMaturity个区块之后才能花费,避免孤块中的奖励被花费
+*/
+package block
+
+//奖励减半的最大次数,超过后奖励为0
+const maxHalvings = 63
+
+//计算高度为height的区块的基础奖励(不考虑总量上限)
+func baseSubsidy(height int) int {
+	if ActiveParams.HalvingInterval <= 0 {
+		return ActiveParams.TokenRewardNum
+	}
+	//创世区块之后的第一个区块为高度2
+	halvings := (height - 2) / ActiveParams.HalvingInterval
+	if halvings >= maxHalvings {
+		return 0
+	}
+	return ActiveParams.TokenRewardNum >> uint(halvings)
+}
+
+//计算高度2到height之间全部区块的基础奖励之和
+func calcSubsidySupply(height int) int {
+	if height < 2 {
+		return 0
+	}
+	if ActiveParams.HalvingInterval <= 0 {
+		return (height - 1) * ActiveParams.TokenRewardNum
+	}
+	supply := 0
+	//按减半周期累加
+	for start := 2; start <= height; start += ActiveParams.HalvingInterval {
+		subsidy := baseSubsidy(start)
+		if subsidy == 0 {
+			break
+		}
+		end := start + ActiveParams.HalvingInterval - 1
+		if end > height {
+			end = height
+		}
+		supply += subsidy * (end - start + 1)
+	}
+	return supply
+}
+
+//计算高度为height的区块的出块奖励,创世区块的代币由创世交易单独分配
+func GetBlockSubsidy(height int) int {
+	if height < 2 {
+		return 0
+	}
+	subsidy := baseSubsidy(height)
+	if ActiveParams.MaxTokenSupply > 0 {
+		remaining := ActiveParams.MaxTokenSupply - calcSubsidySupply(height-1)
+		if remaining < 0 {
+			remaining = 0
+		}
+		if subsidy > remaining {
+			subsidy = remaining
+		}
+	}
+	return subsidy
+}
+
+//判断输出在高度为spendHeight的区块中是否可以花费,创世交易的输出不受限制
+func isMatureUTXO(utxo *UTXO, spendHeight int) bool {
+	if !utxo.Coinbase || utxo.Height <= 1 {
+		return true
+	}
+	return spendHeight-utxo.Height >= ActiveParams.CoinbaseMaturity
+}
+
+//判断交易是否花费了尚未成熟的奖励输出
+func (bc *blockchain) spendsImmatureCoinbase(ts *Transaction, spendHeight int) bool {
+	view := newUTXOView(bc, spendHeight)
+	for _, vIn := range ts.Vint {
+		utxo := view.lookup(vIn.TxHash, vIn.Index)
+		if utxo != nil && !isMatureUTXO(utxo, spendHeight) {
+			return true
+		}
+	}
+	return false
+}
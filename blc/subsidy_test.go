@@ -0,0 +1,54 @@
+package block
+
+import "testing"
+
+func TestBlockSubsidy(t *testing.T) {
+	t.Log("测试出块奖励减半与总量上限")
+	{
+		params := MainNetParams
+		params.TokenRewardNum, params.HalvingInterval, params.MaxTokenSupply = 40, 10, 0
+		ActiveParams = &params
+		defer SetChainParams("main")
+		if s := GetBlockSubsidy(1); s != 0 {
+			t.Fatalf("\t创世区块不应有挖矿奖励：%d", s)
+		}
+		if s := GetBlockSubsidy(11); s != 40 {
+			t.Fatalf("\t第一个减半周期的奖励不正确：%d", s)
+		}
+		if s := GetBlockSubsidy(12); s != 20 {
+			t.Fatalf("\t第一次减半后的奖励不正确：%d", s)
+		}
+		if s := calcSubsidySupply(21); s != 600 {
+			t.Fatalf("\t奖励总量计算不正确：%d", s)
+		}
+		//奖励总量达到上限后不再给予奖励
+		params.MaxTokenSupply = 410
+		if s := GetBlockSubsidy(12); s != 10 {
+			t.Fatalf("\t接近上限时的奖励不正确：%d", s)
+		}
+		if s := GetBlockSubsidy(13); s != 0 {
+			t.Fatalf("\t超过上限后的奖励不正确：%d", s)
+		}
+		t.Log("\t出块奖励计算正确")
+	}
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	t.Log("测试奖励输出的成熟度")
+	{
+		params := MainNetParams
+		params.CoinbaseMaturity = 10
+		ActiveParams = &params
+		defer SetChainParams("main")
+		if isMatureUTXO(&UTXO{nil, 0, TXOutput{}, 5, true}, 14) {
+			t.Fatalf("\t未成熟的奖励输出可以被花费")
+		}
+		if !isMatureUTXO(&UTXO{nil, 0, TXOutput{}, 5, true}, 15) {
+			t.Fatalf("\t成熟的奖励输出不能被花费")
+		}
+		if !isMatureUTXO(&UTXO{nil, 0, TXOutput{}, 5, false}, 6) || !isMatureUTXO(&UTXO{nil, 0, TXOutput{}, 1, true}, 2) {
+			t.Fatalf("\t普通输出与创世输出不受成熟度限制")
+		}
+		t.Log("\t成熟度判断正确")
+	}
+}
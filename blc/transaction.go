@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/corgi-kx/blockchain_golang/util"
 	log "github.com/corgi-kx/logcustom"
 )
@@ -15,32 +18,81 @@ type Transaction struct {
 	Vint []TXInput
 	//UTXO输出
 	Vout []TXOutput
+	//绝对时间锁:小于lockTimeThreshold时交易只能被打包进高度大于LockTime的区块,
+	//否则只能被打包进时间戳大于LockTime的区块,为0时不限制
+	LockTime int64
 }
 
 //对此笔交易的输入,输出进行hash运算后存入交易hash(txhash)
 func (t *Transaction) hash() {
-	tBytes := t.Serialize()
-	//加入随机数byte
-	randomNumber := util.GenerateRealRandom()
-	randomByte := util.Int64ToBytes(randomNumber)
-	sumByte := bytes.Join([][]byte{tBytes, randomByte}, []byte(""))
-	hashByte := sha256.Sum256(sumByte)
-	t.TxHash = hashByte[:]
+	t.TxHash = t.calcTxHash()
+}
+
+//计算交易hash:对交易的规范序列化进行两次sha256,交易hash只由交易内容决定,可以被任何节点独立计算
+func (t *Transaction) calcTxHash() []byte {
+	firstHash := sha256.Sum256(t.canonicalBytes())
+	hashByte := sha256.Sum256(firstHash[:])
+	return hashByte[:]
+}
+
+//检验交易hash是否与交易内容一致
+func (t *Transaction) VerifyTxHash() bool {
+	return bytes.Equal(t.TxHash, t.calcTxHash())
+}
+
+//交易的规范序列化,变长字段前都加上长度,保证不同的交易不会拼接出相同的字节数组
+//解锁脚本不参与计算(类似隔离见证),所以对交易重新签名不会改变交易hash
+func (t *Transaction) canonicalBytes() []byte {
+	data := []byte{}
+	data = append(data, util.Int64ToBytes(int64(len(t.Vint)))...)
+	for _, v := range t.Vint {
+		data = append(data, lengthPrefixed(v.TxHash)...)
+		data = append(data, util.Int64ToBytes(int64(v.Index))...)
+		//奖励交易的输入没有签名,解锁脚本存放的是区块高度,需要参与计算以区分不同区块的奖励交易
+		if v.Index == -1 {
+			data = append(data, lengthPrefixed(v.ScriptSig)...)
+		}
+		data = append(data, util.Int64ToBytes(int64(v.Sequence))...)
+	}
+	data = append(data, util.Int64ToBytes(int64(len(t.Vout)))...)
+	for _, v := range t.Vout {
+		data = append(data, util.Int64ToBytes(int64(v.Value))...)
+		data = append(data, lengthPrefixed(v.ScriptPubKey)...)
+	}
+	data = append(data, util.Int64ToBytes(t.LockTime)...)
+	return data
+}
+
+//交易大小(字节):规范序列化的长度加上解锁脚本的长度
+func (t *Transaction) Size() int {
+	size := len(t.canonicalBytes())
+	for _, v := range t.Vint {
+		size += len(lengthPrefixed(v.ScriptSig))
+	}
+	return size
+}
+
+//在字节数组前加上长度
+func lengthPrefixed(b []byte) []byte {
+	return append(util.Int64ToBytes(int64(len(b))), b...)
 }
 
 //作为数字签名的hash方法，为什么不用gob序列化后hash，因为涉及到tcp传输gob直接序列化有问题，所以单独拼接成byte数组再hash
 func (t *Transaction) hashSign() []byte {
 	t.TxHash = nil
-	nHash := []byte{}
+	//签名hash以当前网络的链id开头,防止交易被重放到其他网络
+	nHash := util.Int64ToBytes(int64(ActiveParams.ChainID))
 	for _, v := range t.Vint {
 		nHash = append(nHash, v.TxHash...)
-		nHash = append(nHash, v.PublicKey...)
+		nHash = append(nHash, v.ScriptSig...)
 		nHash = append(nHash, util.Int64ToBytes(int64(v.Index))...)
+		nHash = append(nHash, util.Int64ToBytes(int64(v.Sequence))...)
 	}
 	for _, v := range t.Vout {
-		nHash = append(nHash, v.PublicKeyHash...)
+		nHash = append(nHash, v.ScriptPubKey...)
 		nHash = append(nHash, util.Int64ToBytes(int64(v.Value))...)
 	}
+	nHash = append(nHash, util.Int64ToBytes(t.LockTime)...)
 	hashByte := sha256.Sum256(nHash)
 	return hashByte[:]
 }
@@ -57,25 +109,36 @@ func (t *Transaction) Serialize() []byte {
 	return result.Bytes()
 }
 
-//将整笔交易里的成员依次转换成字节数组,拼接成整体后 返回
-func (t *Transaction) getTransBytes() []byte {
+//从十六进制字符串中解析出交易,并校验交易hash与交易内容是否一致
+func DeserializeTransaction(data string) (*Transaction, error) {
+	b, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, errors.New("交易数据不是十六进制编码")
+	}
+	t := &Transaction{}
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(t)
+	if err != nil {
+		return nil, fmt.Errorf("交易数据解析失败:%s", err)
+	}
+	if !t.VerifyTxHash() {
+		return nil, fmt.Errorf("交易%x的交易hash与交易内容不一致", t.TxHash)
+	}
+	return t, nil
+}
+
+//默克尔树的叶节点数据:交易hash加上解锁脚本的hash
+//交易hash已经包含了交易的输入输出,再加上解锁脚本的hash,保证区块头同样能锁定交易的签名
+func (t *Transaction) merkleLeaf() []byte {
 	if t.TxHash == nil || t.Vout == nil {
 		log.Panic("交易信息不完整，无法拼接成字节数组")
 		return nil
 	}
-	transBytes := []byte{}
-	transBytes = append(transBytes, t.TxHash...)
+	witness := []byte{}
 	for _, v := range t.Vint {
-		transBytes = append(transBytes, v.TxHash...)
-		transBytes = append(transBytes, util.Int64ToBytes(int64(v.Index))...)
-		transBytes = append(transBytes, v.Signature...)
-		transBytes = append(transBytes, v.PublicKey...)
-	}
-	for _, v := range t.Vout {
-		transBytes = append(transBytes, util.Int64ToBytes(int64(v.Value))...)
-		transBytes = append(transBytes, v.PublicKeyHash...)
+		witness = append(witness, lengthPrefixed(v.ScriptSig)...)
 	}
-	return transBytes
+	witnessHash := sha256.Sum256(witness)
+	return append(append([]byte{}, t.TxHash...), witnessHash[:]...)
 }
 
 //从原交易里拷贝出一个新的交易
@@ -83,20 +146,59 @@ func (t *Transaction) customCopy() Transaction {
 	newVin := []TXInput{}
 	newVout := []TXOutput{}
 	for _, vin := range t.Vint {
-		newVin = append(newVin, TXInput{vin.TxHash, vin.Index, nil, nil})
+		newVin = append(newVin, TXInput{vin.TxHash, vin.Index, nil, vin.Sequence})
 	}
 	for _, vout := range t.Vout {
-		newVout = append(newVout, TXOutput{vout.Value, vout.PublicKeyHash})
+		newVout = append(newVout, TXOutput{vout.Value, vout.ScriptPubKey})
 	}
-	return Transaction{t.TxHash, newVin, newVout}
+	return Transaction{t.TxHash, newVin, newVout, t.LockTime}
 }
 
-//判断是否是创世区块的交易
-func isGenesisTransaction(tss []Transaction) bool {
-	if tss != nil {
-		if tss[0].Vint[0].Index == -1 {
+//计算第index个输入的签名hash:拷贝交易并清空所有解锁脚本,再将该输入的解锁脚本替换为所花费输出的锁定脚本后整体hash
+func (t *Transaction) signatureHash(index int, scriptPubKey []byte) []byte {
+	copyTs := t.customCopy()
+	copyTs.Vint[index].ScriptSig = scriptPubKey
+	return copyTs.hashSign()
+}
+
+//执行交易中第index个输入的解锁脚本与所花费输出prevOut的锁定脚本
+func (t *Transaction) verifyInputScript(index int, prevOut TXOutput) error {
+	return verifyScript(t, index, prevOut)
+}
+
+//交易能否被打包进高度为height的区块(是否已过绝对时间锁),timeStamp为上一个区块的过去中位时间
+func (t *Transaction) IsFinal(height int, timeStamp int64) bool {
+	if t.LockTime == 0 {
+		return true
+	}
+	if t.LockTime < lockTimeThreshold {
+		return t.LockTime < int64(height)
+	}
+	return t.LockTime < timeStamp
+}
+
+//交易是否声明了可以被替换:任意一个输入设置了可替换标志即可
+func (t *Transaction) IsReplaceable() bool {
+	for _, v := range t.Vint {
+		if v.Sequence&SequenceReplaceable != 0 {
 			return true
 		}
 	}
 	return false
 }
+
+//判断是否是奖励交易(只有一个索引为-1的输入),创世交易就是创世区块的奖励交易
+func (t *Transaction) IsCoinbase() bool {
+	return len(t.Vint) == 1 && t.Vint[0].Index == -1
+}
+
+//生成奖励交易的输入,解锁脚本存放区块高度
+func newCoinbaseInput(height int) TXInput {
+	return TXInput{nil, -1, util.Int64ToBytes(int64(height)), 0}
+}
+
+//设置奖励交易中的额外随机数(放在区块高度之后),挖矿时nonce用尽后通过改变额外随机数得到新的默克尔根
+func (t *Transaction) setExtraNonce(height int, extraNonce []byte) {
+	t.Vint[0].ScriptSig = append(util.Int64ToBytes(int64(height)), extraNonce...)
+	t.hash()
+}
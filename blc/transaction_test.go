@@ -0,0 +1,80 @@
+package block
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTxHash(t *testing.T) {
+	t.Log("测试交易hash由交易内容唯一确定")
+	{
+		ts := Transaction{nil, []TXInput{{[]byte{1, 2, 3}, 0, newP2PKHSigScript([]byte("sign"), []byte("pubkey")), 0}}, []TXOutput{{10, []byte("pkh")}}, 0}
+		ts.hash()
+		other := Transaction{nil, []TXInput{{[]byte{1, 2, 3}, 0, nil, 0}}, []TXOutput{{10, []byte("pkh")}}, 0}
+		other.hash()
+		//解锁脚本不参与计算
+		if !bytes.Equal(ts.TxHash, other.TxHash) {
+			t.Fatalf("\t修改签名后交易hash发生了变化")
+		}
+		if !ts.VerifyTxHash() {
+			t.Fatalf("\t交易hash校验不通过")
+		}
+		ts.Vout[0].Value = 11
+		if ts.VerifyTxHash() {
+			t.Fatalf("\t修改输出金额后交易hash校验仍然通过")
+		}
+		//不同高度的奖励交易hash不同
+		a := Transaction{nil, []TXInput{newCoinbaseInput(2)}, []TXOutput{{10, []byte("pkh")}}, 0}
+		b := Transaction{nil, []TXInput{newCoinbaseInput(3)}, []TXOutput{{10, []byte("pkh")}}, 0}
+		if bytes.Equal(a.calcTxHash(), b.calcTxHash()) {
+			t.Fatalf("\t不同高度的奖励交易hash相同")
+		}
+		t.Log("\t交易hash计算正确")
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	t.Log("测试交易的绝对时间锁:按区块高度或时间戳判断交易能否被打包")
+	{
+		ts := Transaction{nil, []TXInput{{[]byte{1, 2, 3}, 0, nil, 0}}, []TXOutput{{10, []byte("pkh")}}, 0}
+		ts.hash()
+		if !ts.IsFinal(1, 0) {
+			t.Fatalf("\tLockTime为0的交易不能被打包")
+		}
+		ts.LockTime = 100
+		if ts.VerifyTxHash() {
+			t.Fatalf("\t修改LockTime后交易hash校验仍然通过")
+		}
+		if ts.IsFinal(100, lockTimeThreshold+1000) || !ts.IsFinal(101, 0) {
+			t.Fatalf("\t按区块高度判断时间锁不正确")
+		}
+		ts.LockTime = lockTimeThreshold + 100
+		if ts.IsFinal(1000, lockTimeThreshold+100) || !ts.IsFinal(1, lockTimeThreshold+101) {
+			t.Fatalf("\t按时间戳判断时间锁不正确")
+		}
+		t.Log("\t绝对时间锁判断正确")
+	}
+}
+
+func TestMedianTimeStamp(t *testing.T) {
+	t.Log("测试时间锁使用之前区块时间戳的中位数,而不是单个区块的时间戳")
+	{
+		if medianTimeStamp(nil) != 0 || medianTimeStamp([]int64{5}) != 5 {
+			t.Fatalf("	区块数量不足时中位数不正确")
+		}
+		//最新区块的时间戳被调到很远的将来,中位数不受影响
+		timeStamps := []int64{lockTimeThreshold + 1e9, 110, 100, 90, 80, 70, 60, 50, 40, 30, 20}
+		median := medianTimeStamp(timeStamps)
+		if median != 70 {
+			t.Fatalf("	中位数不正确:%d", median)
+		}
+		if timeStamps[0] != lockTimeThreshold+1e9 {
+			t.Fatalf("	计算中位数时修改了传入的时间戳")
+		}
+		ts := Transaction{nil, []TXInput{{[]byte{1, 2, 3}, 0, nil, 0}}, []TXOutput{{10, []byte("pkh")}}, lockTimeThreshold + 100}
+		if ts.IsFinal(1, median) {
+			t.Fatalf("	调整最新区块的时间戳后,尚未到期的交易可以被打包")
+		}
+		t.Log("	过去中位时间计算正确")
+	}
+}
@@ -2,8 +2,20 @@ package block
 
 //UTXO输入
 type TXInput struct {
-	TxHash    []byte
-	Index     int
-	Signature []byte
-	PublicKey []byte
+	TxHash []byte
+	Index  int
+	//解锁脚本,奖励交易的解锁脚本存放区块高度与额外随机数
+	ScriptSig []byte
+	//最高位为可替换(RBF)标志,其余位为相对时间锁:所花费的输出被打包后至少还需要经过的区块数量,为0时不限制
+	Sequence uint32
+}
+
+//相对时间锁的区块数量(去掉可替换标志位)
+func (i TXInput) RelativeLock() int {
+	return int(i.Sequence &^ SequenceReplaceable)
+}
+
+//获取P2PKH解锁脚本中的公钥,其他类型的解锁脚本返回nil
+func (i TXInput) PublicKey() []byte {
+	return extractSigScriptPublicKey(i.ScriptSig)
 }
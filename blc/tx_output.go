@@ -2,6 +2,23 @@ package block
 
 //UTXO输出
 type TXOutput struct {
-	Value         int
-	PublicKeyHash []byte
+	Value int
+	//锁定脚本,规定了花费此输出需要满足的条件
+	ScriptPubKey []byte
+}
+
+//获取P2PKH输出的公钥hash,其他类型的锁定脚本返回nil
+func (o TXOutput) PublicKeyHash() []byte {
+	return extractPublicKeyHash(o.ScriptPubKey)
+}
+
+//获取输出的地址:P2PKH输出为普通地址,P2SH输出为多重签名地址,其他类型的锁定脚本返回空字符串
+func (o TXOutput) Address() string {
+	if publicKeyHash := o.PublicKeyHash(); publicKeyHash != nil {
+		return GetAddressFromPublicKeyHash(publicKeyHash)
+	}
+	if scriptHash := extractScriptHash(o.ScriptPubKey); scriptHash != nil {
+		return GetAddressFromScriptHash(scriptHash)
+	}
+	return ""
 }
@@ -0,0 +1,81 @@
+/*
+	交易存在性证明:只需提供区块头中的默克尔根与一条默克尔路径,
+	就能向对方证明某笔交易被打包进了某个区块,无需发送整个区块
+*/
+package block
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/util"
+)
+
+//交易的默克尔证明
+type TxProof struct {
+	//交易所在区块的hash
+	BlockHash []byte
+	//交易所在区块的高度
+	Height int
+	//区块头中的默克尔根
+	MerkleRoot []byte
+	//交易hash
+	TxHash []byte
+	//默克尔树的叶节点数据(交易hash+签名数据hash)
+	Leaf []byte
+	//从叶节点到默克尔根的路径
+	Proof []util.MerkelProofNode
+}
+
+//在主链中查找交易,并生成该交易的默克尔证明
+func (bc *blockchain) GetTxProof(txHash []byte) (*TxProof, error) {
+	bci := NewBlockchainIterator(bc)
+	for {
+		block := bci.Next()
+		if block == nil {
+			return nil, fmt.Errorf("GetTxProof err : 主链中找不到交易%x", txHash)
+		}
+		for index := range block.Transactions {
+			ts := &block.Transactions[index]
+			if !bytes.Equal(ts.TxHash, txHash) {
+				continue
+			}
+			transData := [][]byte{}
+			for i := range block.Transactions {
+				transData = append(transData, block.Transactions[i].merkleLeaf())
+			}
+			proof, err := util.NewMerkelTree(transData).GenerateProof(index)
+			if err != nil {
+				return nil, err
+			}
+			return &TxProof{block.Hash, block.Height, block.MerkleRoot, ts.TxHash, transData[index], proof}, nil
+		}
+	}
+}
+
+//验证默克尔证明:叶节点对应该交易,且能通过默克尔路径计算出默克尔根
+//对方还需自行确认默克尔根所在的区块头处于主链上
+func (p *TxProof) Verify() bool {
+	if !bytes.HasPrefix(p.Leaf, p.TxHash) {
+		return false
+	}
+	return util.VerifyProof(p.Leaf, p.Proof, p.MerkleRoot)
+}
+
+//以文本形式输出默克尔证明,便于发送给对方
+func (p *TxProof) String() string {
+	s := fmt.Sprintf("交易hash:    %x\n", p.TxHash)
+	s += fmt.Sprintf("区块hash:    %x\n", p.BlockHash)
+	s += fmt.Sprintf("区块高度:    %d\n", p.Height)
+	s += fmt.Sprintf("默克尔根:    %x\n", p.MerkleRoot)
+	s += fmt.Sprintf("叶节点数据:  %x\n", p.Leaf)
+	s += "默克尔路径:\n"
+	for _, node := range p.Proof {
+		side := "右"
+		if node.IsLeft {
+			side = "左"
+		}
+		s += fmt.Sprintf("\t%s %s\n", side, hex.EncodeToString(node.Hash))
+	}
+	return s
+}
@@ -5,4 +5,8 @@ type UTXO struct {
 	Hash  []byte
 	Index int
 	Vout  TXOutput
+	//输出所在区块的高度
+	Height int
+	//是否为奖励交易的输出
+	Coinbase bool
 }
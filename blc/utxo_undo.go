@@ -0,0 +1,170 @@
+/*
+	区块撤销日志:每接入一个区块,就把该区块花费掉的输出原样记录到undo仓库中,
+	这样断开区块时只需按日志恢复,无需遍历整条区块链重建utxo数据库;
+	撤销日志、utxo的修改与utxo数据库对应的区块hash在同一个事务中提交,中途崩溃不会留下修改了一半的utxo数据库
+*/
+package block
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/database"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//将区块接入utxo数据库,并记录撤销日志,全部修改写入batch,由调用者提交
+func (u *UTXOHandle) ConnectBlock(block *Block, batch *database.Batch) {
+	batch.Put(block.Hash, u.serialize(u.findSpentUTXOs(block, batch)), database.UndoBucket)
+	u.Synchrodata(batch, block.Transactions, block.Height)
+	batch.Put([]byte(utxoTipMapping), block.Hash, database.UndoBucket)
+}
+
+//将区块从utxo数据库中断开,并删除撤销日志,全部修改写入batch,由调用者提交
+func (u *UTXOHandle) DisconnectBlock(block *Block, batch *database.Batch) {
+	u.Rollback(block, batch)
+	batch.Delete(block.Hash, database.UndoBucket)
+	batch.Put([]byte(utxoTipMapping), block.PreHash, database.UndoBucket)
+}
+
+//找出区块中交易将要花费的、当前存在于utxo数据库中的输出
+func (u *UTXOHandle) findSpentUTXOs(block *Block, batch *database.Batch) []*UTXO {
+	spent := []*UTXO{}
+	for _, ts := range block.Transactions {
+		for _, vIn := range ts.Vint {
+			if vIn.Index == -1 {
+				continue
+			}
+			utxoByte := batch.View(vIn.TxHash, database.UTXOBucket)
+			//花费的是本块中靠前交易的输出,回滚时直接删除即可,无需记录
+			if len(utxoByte) == 0 {
+				continue
+			}
+			for _, utxo := range u.dserialize(utxoByte) {
+				if utxo.Index == vIn.Index {
+					spent = append(spent, utxo)
+				}
+			}
+		}
+	}
+	return spent
+}
+
+//传入要回滚的区块,撤销该区块对utxo数据库的修改:删除区块中交易产生的输出,恢复被区块中交易花费的输出
+func (u *UTXOHandle) Rollback(block *Block, batch *database.Batch) {
+	//读取撤销日志
+	undo := map[string]*UTXO{}
+	undoByte := batch.View(block.Hash, database.UndoBucket)
+	hasUndo := len(undoByte) != 0
+	if hasUndo {
+		for _, utxo := range u.dserialize(undoByte) {
+			undo[utxoKey(utxo.Hash, utxo.Index)] = utxo
+		}
+	}
+	//倒序撤销,保证区块内前后依赖的交易能正确恢复
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		ts := block.Transactions[i]
+		batch.Delete(ts.TxHash, database.UTXOBucket)
+		for _, vIn := range ts.Vint {
+			if vIn.Index == -1 {
+				continue
+			}
+			var spent *UTXO
+			if hasUndo {
+				spent = undo[utxoKey(vIn.TxHash, vIn.Index)]
+				//撤销日志中没有记录的是本块内交易的输出,已随交易一并删除
+				if spent == nil {
+					continue
+				}
+			} else {
+				//旧版本数据库没有撤销日志,只能从区块链中查找被花费的输出及其所在区块的高度,
+				//高度决定奖励输出是否成熟,不能随意填写
+				preTs, height, err := u.findTransactionWithHeight(block, i, vIn.TxHash)
+				if err != nil {
+					log.Panic("Rollback err : ", err)
+				}
+				spent = &UTXO{vIn.TxHash, vIn.Index, preTs.Vout[vIn.Index], height, preTs.IsCoinbase()}
+			}
+			u.restoreUTXO(spent, batch)
+		}
+	}
+}
+
+//查找区块中第index笔交易花费的交易,返回该交易与它所在区块的高度:先在本块靠前的交易中查找,再沿着上一个区块向前查找
+func (u *UTXOHandle) findTransactionWithHeight(block *Block, index int, hash []byte) (*Transaction, int, error) {
+	for i := index - 1; i >= 0; i-- {
+		if bytes.Equal(block.Transactions[i].TxHash, hash) {
+			return &block.Transactions[i], block.Height, nil
+		}
+	}
+	for current := block; !isGenesisBlock(current); {
+		current = u.BC.getBlock(current.PreHash)
+		if current == nil {
+			break
+		}
+		for i := range current.Transactions {
+			if bytes.Equal(current.Transactions[i].TxHash, hash) {
+				return &current.Transactions[i], current.Height, nil
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("找不到交易%x", hash)
+}
+
+//将输出重新放回utxo数据库,已存在则跳过,保证多次回滚结果一致
+func (u *UTXOHandle) restoreUTXO(spent *UTXO, batch *database.Batch) {
+	utxos := []*UTXO{}
+	utxoByte := batch.View(spent.Hash, database.UTXOBucket)
+	if len(utxoByte) != 0 {
+		utxos = u.dserialize(utxoByte)
+	}
+	for _, utxo := range utxos {
+		if utxo.Index == spent.Index {
+			return
+		}
+	}
+	utxos = append(utxos, spent)
+	batch.Put(spent.Hash, u.serialize(utxos), database.UTXOBucket)
+}
+
+//节点启动时检查utxo数据库是否与主链一致,如果上次退出时同步中断,则通过撤销日志恢复
+func (bc *blockchain) RecoverUTXO() {
+	lastHash := bc.BD.View([]byte(LastBlockHashMapping), database.BlockBucket)
+	if len(lastHash) == 0 {
+		return
+	}
+	u := UTXOHandle{bc}
+	utxoTip := bc.BD.View([]byte(utxoTipMapping), database.UndoBucket)
+	//旧版本数据库没有记录,只能完整重建一次
+	if len(utxoTip) == 0 {
+		log.Info("utxo数据库没有同步记录,开始重置utxo数据库")
+		u.ResetUTXODataBase()
+		return
+	}
+	if bytes.Equal(utxoTip, lastHash) {
+		return
+	}
+	log.Warnf("utxo数据库对应区块%x与最新区块%x不一致,开始恢复", utxoTip, lastHash)
+	forkBlock, err := bc.findForkBlock(utxoTip, lastHash)
+	if err != nil {
+		log.Error(err)
+		u.ResetUTXODataBase()
+		return
+	}
+	//断开utxo数据库多接入的区块
+	for current := bc.getBlock(utxoTip); !bytes.Equal(current.Hash, forkBlock.Hash); current = bc.getBlock(current.PreHash) {
+		batch := bc.BD.NewBatch()
+		u.DisconnectBlock(current, batch)
+		batch.Commit()
+	}
+	//补上主链上尚未接入的区块
+	attach := []*Block{}
+	for current := bc.getBlock(lastHash); !bytes.Equal(current.Hash, forkBlock.Hash); current = bc.getBlock(current.PreHash) {
+		attach = append(attach, current)
+	}
+	for i := len(attach) - 1; i >= 0; i-- {
+		batch := bc.BD.NewBatch()
+		u.ConnectBlock(attach[i], batch)
+		batch.Commit()
+	}
+	log.Info("utxo数据库已恢复至与最新区块一致")
+}
@@ -0,0 +1,89 @@
+package block
+
+import (
+	"bytes"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"reflect"
+	"testing"
+)
+
+func TestConnectDisconnectBlock(t *testing.T) {
+	t.Log("测试接入再断开区块后utxo数据库与撤销日志恢复原样")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		genesis := ActiveParams.GenesisBlock()
+		a1 := mineTestBlock(t, bc, genesis, miner, nil, 'a')
+		addTestBlock(t, bc, a1)
+		a2 := mineTestBlock(t, bc, a1, miner, []Transaction{newTestPayment(miner, &a1.Transactions[0], 0, payee, 0)}, 'a')
+		utxos := dumpTestBucket(t, database.UTXOBucket)
+		undo := dumpTestBucket(t, database.UndoBucket)
+		u := UTXOHandle{bc}
+		batch := bc.BD.NewBatch()
+		u.ConnectBlock(a2, batch)
+		batch.Commit()
+		if reflect.DeepEqual(utxos, dumpTestBucket(t, database.UTXOBucket)) {
+			t.Fatalf("\t接入区块后utxo数据库没有变化")
+		}
+		batch = bc.BD.NewBatch()
+		u.DisconnectBlock(a2, batch)
+		batch.Commit()
+		if !reflect.DeepEqual(utxos, dumpTestBucket(t, database.UTXOBucket)) || !reflect.DeepEqual(undo, dumpTestBucket(t, database.UndoBucket)) {
+			t.Fatalf("\t断开区块后utxo数据库或撤销日志没有恢复原样")
+		}
+		t.Log("\t断开区块后已恢复原样")
+	}
+	t.Log("测试没有撤销日志时断开区块,恢复的奖励输出保留原来的区块高度")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		genesis := ActiveParams.GenesisBlock()
+		a1 := mineTestBlock(t, bc, genesis, miner, nil, 'a')
+		addTestBlock(t, bc, a1)
+		a2 := mineTestBlock(t, bc, a1, miner, []Transaction{newTestPayment(miner, &a1.Transactions[0], 0, payee, 0)}, 'a')
+		utxos := dumpTestBucket(t, database.UTXOBucket)
+		u := UTXOHandle{bc}
+		batch := bc.BD.NewBatch()
+		u.ConnectBlock(a2, batch)
+		batch.Commit()
+		//模拟旧版本数据库
+		bc.BD.Delete(a2.Hash, database.UndoBucket)
+		batch = bc.BD.NewBatch()
+		u.DisconnectBlock(a2, batch)
+		batch.Commit()
+		if !reflect.DeepEqual(utxos, dumpTestBucket(t, database.UTXOBucket)) {
+			t.Fatalf("\t没有撤销日志时恢复的输出与原来不一致")
+		}
+		t.Log("\t恢复的奖励输出高度正确")
+	}
+}
+
+func TestRecoverUTXO(t *testing.T) {
+	t.Log("测试utxo数据库落后于最新区块时,启动节点会补上尚未接入的区块")
+	{
+		bc := newTestChain(t)
+		miner, payee := newTestKeys(t), newTestKeys(t)
+		genesis := ActiveParams.GenesisBlock()
+		a1 := mineTestBlock(t, bc, genesis, miner, nil, 'a')
+		addTestBlock(t, bc, a1)
+		a2 := mineTestBlock(t, bc, a1, miner, []Transaction{newTestPayment(miner, &a1.Transactions[0], 0, payee, 0)}, 'a')
+		//模拟区块已写入但utxo数据库还没有同步时退出
+		bc.BD.Put(a2.Hash, a2.Serialize(), database.BlockBucket)
+		bc.BD.Put(a2.Hash, a2.BlockHeader.Serialize(), database.HeaderBucket)
+		bc.BD.Put([]byte(LastBlockHashMapping), a2.Hash, database.BlockBucket)
+		bc.RecoverUTXO()
+		if !bytes.Equal(bc.BD.View([]byte(utxoTipMapping), database.UndoBucket), a2.Hash) {
+			t.Fatalf("\t恢复后utxo数据库对应的区块不是最新区块")
+		}
+		utxos := dumpTestUTXOs(t, bc)
+		if _, ok := utxos[string(a1.Transactions[0].TxHash)]; ok {
+			t.Fatalf("\t恢复后已花费的输出仍在utxo数据库中")
+		}
+		u := UTXOHandle{bc}
+		u.ResetUTXODataBase()
+		if !reflect.DeepEqual(utxos, dumpTestUTXOs(t, bc)) {
+			t.Fatalf("\t恢复后的utxo数据库与重新同步的结果不一致")
+		}
+		t.Log("\tutxo数据库已恢复")
+	}
+}
@@ -33,11 +33,25 @@ func (u *UTXOHandle) ResetUTXODataBase() {
 	for k, v := range utxosMap {
 		u.BC.BD.Put([]byte(k), u.serialize(v), database.UTXOBucket)
 	}
+	//记录utxo数据库已与最新区块一致
+	u.BC.BD.Put([]byte(utxoTipMapping), u.BC.BD.View([]byte(LastBlockHashMapping), database.BlockBucket), database.UndoBucket)
+}
+
+//根据地址查找在下一个区块中可以花费的utxo(剔除尚未成熟的奖励输出)
+func (u *UTXOHandle) findSpendableUTXOFromAddress(address string) []*UTXO {
+	spendHeight := u.BC.GetLastBlockHeight() + 1
+	spendable := []*UTXO{}
+	for _, utxo := range u.findUTXOFromAddress(address) {
+		if isMatureUTXO(utxo, spendHeight) {
+			spendable = append(spendable, utxo)
+		}
+	}
+	return spendable
 }
 
 //根据地址未消费的utxo
 func (u *UTXOHandle) findUTXOFromAddress(address string) []*UTXO {
-	publicKeyHash := getPublicKeyHashFromAddress(address)
+	lockScript := NewScriptForAddress(address)
 	utxosSlice := []*UTXO{}
 	//获取bolt迭代器，遍历整个UTXO数据库
 	//打开数据库
@@ -57,7 +71,7 @@ func (u *UTXOHandle) findUTXOFromAddress(address string) []*UTXO {
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			utxos := u.dserialize(v)
 			for _, utxo := range utxos {
-				if bytes.Equal(utxo.Vout.PublicKeyHash, publicKeyHash) {
+				if bytes.Equal(utxo.Vout.ScriptPubKey, lockScript) {
 					utxosSlice = append(utxosSlice, utxo)
 				}
 			}
@@ -75,36 +89,40 @@ func (u *UTXOHandle) findUTXOFromAddress(address string) []*UTXO {
 	return utxosSlice
 }
 
-//传入交易信息,将交易里的输出添加进utxo数据库,并剔除输入信息
-func (u *UTXOHandle) Synchrodata(tss []Transaction) {
+//传入交易信息及其所在区块高度,将交易里的输出添加进utxo数据库,并剔除输入信息
+//修改写入batch,由调用者提交
+func (u *UTXOHandle) Synchrodata(batch *database.Batch, tss []Transaction, height int) {
 	//先将全部输入插入数据库
 	for _, ts := range tss {
 		utxos := []*UTXO{}
 		for index, vOut := range ts.Vout {
-			utxos = append(utxos, &UTXO{ts.TxHash, index, vOut})
+			utxos = append(utxos, &UTXO{ts.TxHash, index, vOut, height, ts.IsCoinbase()})
 		}
-		u.BC.BD.Put(ts.TxHash, u.serialize(utxos), database.UTXOBucket)
+		batch.Put(ts.TxHash, u.serialize(utxos), database.UTXOBucket)
 	}
 
 	//在用输出进行剔除
 	for _, ts := range tss {
 		for _, vIn := range ts.Vint {
-			publicKeyHash := generatePublicKeyHash(vIn.PublicKey)
+			//创世交易的输入没有对应的utxo
+			if vIn.Index == -1 {
+				continue
+			}
 			//获取bolt迭代器，遍历整个UTXO数据库
-			utxoByte := u.BC.BD.View(vIn.TxHash, database.UTXOBucket)
+			utxoByte := batch.View(vIn.TxHash, database.UTXOBucket)
 			if len(utxoByte) == 0 {
 				log.Panic("Synchrodata err : do not find utxo")
 			}
 			utxos := u.dserialize(utxoByte)
 			newUTXO := []*UTXO{}
 			for _, utxo := range utxos {
-				if utxo.Index == vIn.Index && bytes.Equal(utxo.Vout.PublicKeyHash, publicKeyHash) {
+				if utxo.Index == vIn.Index {
 					continue
 				}
 				newUTXO = append(newUTXO, utxo)
 			}
-			u.BC.BD.Delete(vIn.TxHash, database.UTXOBucket)
-			u.BC.BD.Put(vIn.TxHash, u.serialize(newUTXO), database.UTXOBucket)
+			batch.Delete(vIn.TxHash, database.UTXOBucket)
+			batch.Put(vIn.TxHash, u.serialize(newUTXO), database.UTXOBucket)
 		}
 	}
 }
@@ -0,0 +1,352 @@
+/*
+	区块共识校验:本地挖出的区块与网络中接收到的区块都通过ValidateBlock进行同样的校验,
+	校验不通过时返回带有拒绝原因的ValidationError
+*/
+package block
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/util"
+)
+
+//区块被拒绝的原因
+type RejectReason int
+
+const (
+	RejectMissingParent RejectReason = iota + 1
+	RejectBadHash
+	RejectBadMerkleRoot
+	RejectBadHeader
+	RejectNotOnTip
+	RejectBadTransaction
+	RejectBadTxHash
+	RejectDuplicateTransaction
+	RejectBadCoinbase
+	RejectMissingInputs
+	RejectDoubleSpend
+	RejectBadSignature
+	RejectInsufficientFunds
+	RejectImmatureCoinbase
+	RejectNonFinal
+	RejectSequenceLock
+	RejectOversize
+)
+
+var rejectReasonStrings = map[RejectReason]string{
+	RejectMissingParent:        "找不到上一个区块",
+	RejectBadHash:              "区块hash错误",
+	RejectBadMerkleRoot:        "默克尔根错误",
+	RejectBadHeader:            "区块头校验失败",
+	RejectNotOnTip:             "区块不是接在当前最新区块之后",
+	RejectBadTransaction:       "交易格式错误",
+	RejectBadTxHash:            "交易hash与交易内容不一致",
+	RejectDuplicateTransaction: "区块中存在重复的交易",
+	RejectBadCoinbase:          "奖励交易错误",
+	RejectMissingInputs:        "交易输入引用的输出不存在",
+	RejectDoubleSpend:          "双花",
+	RejectBadSignature:         "脚本或数字签名验证失败",
+	RejectInsufficientFunds:    "交易输出金额大于输入金额",
+	RejectImmatureCoinbase:     "花费了尚未成熟的奖励输出",
+	RejectNonFinal:             "交易尚未到达锁定时间",
+	RejectSequenceLock:         "输入尚未满足相对时间锁",
+	RejectOversize:             "区块或交易超过大小上限",
+}
+
+func (r RejectReason) String() string {
+	if s, ok := rejectReasonStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("未知原因(%d)", int(r))
+}
+
+//区块校验错误
+type ValidationError struct {
+	Reason RejectReason
+	Msg    string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%s", e.Reason, e.Msg)
+}
+
+func newValidationError(reason RejectReason, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{reason, fmt.Sprintf(format, a...)}
+}
+
+//完整的区块校验,区块必须接在当前utxo数据库对应的最新区块之后
+func (bc *blockchain) ValidateBlock(block *Block) error {
+	err := bc.checkBlockSanity(block)
+	if err != nil {
+		return err
+	}
+	err = bc.checkBlockHeader(block)
+	if err != nil {
+		return err
+	}
+	return bc.checkBlockContext(block)
+}
+
+//不依赖区块链状态的校验:区块hash、区块大小与交易数量、默克尔根、交易格式、奖励交易数量
+func (bc *blockchain) checkBlockSanity(block *Block) error {
+	if !bytes.Equal(block.Hash, block.CalcHash()) {
+		return newValidationError(RejectBadHash, "区块hash%x与区块头计算结果不一致", block.Hash)
+	}
+	if len(block.Transactions) == 0 {
+		return newValidationError(RejectBadTransaction, "区块中没有交易")
+	}
+	if len(block.Transactions) > MaxBlockTransactions {
+		return newValidationError(RejectOversize, "区块中有%d笔交易,超过上限%d", len(block.Transactions), MaxBlockTransactions)
+	}
+	if size := block.Size(); size > MaxBlockSize {
+		return newValidationError(RejectOversize, "区块大小%d字节,超过上限%d", size, MaxBlockSize)
+	}
+	if !bytes.Equal(block.MerkleRoot, calcMerkleRoot(block.Transactions)) {
+		return newValidationError(RejectBadMerkleRoot, "区块头中的默克尔根与交易数据不一致")
+	}
+	//区块的第一笔交易必须是奖励交易,之后不能再出现奖励交易
+	if !block.Transactions[0].IsCoinbase() {
+		return newValidationError(RejectBadCoinbase, "区块的第一笔交易不是奖励交易")
+	}
+	txHashes := map[string]bool{}
+	for i := range block.Transactions {
+		ts := &block.Transactions[i]
+		err := checkTransactionSanity(ts)
+		if err != nil {
+			return err
+		}
+		if txHashes[string(ts.TxHash)] {
+			return newValidationError(RejectDuplicateTransaction, "交易%x重复出现", ts.TxHash)
+		}
+		txHashes[string(ts.TxHash)] = true
+		if ts.IsCoinbase() {
+			if i != 0 {
+				return newValidationError(RejectBadCoinbase, "区块的第%d笔交易%x是多余的奖励交易", i+1, ts.TxHash)
+			}
+			//奖励交易中必须以本块高度开头,之后最多跟随maxExtraNonceSize字节的额外随机数
+			sig := ts.Vint[0].ScriptSig
+			if !bytes.HasPrefix(sig, util.Int64ToBytes(int64(block.Height))) || len(sig) > 8+maxExtraNonceSize {
+				return newValidationError(RejectBadCoinbase, "奖励交易%x中的高度与区块高度%d不一致", ts.TxHash, block.Height)
+			}
+		}
+	}
+	return nil
+}
+
+//不依赖区块链状态的交易校验:交易格式、输入输出数量与交易大小、输出金额、交易hash
+func checkTransactionSanity(ts *Transaction) error {
+	if len(ts.TxHash) == 0 || len(ts.Vout) == 0 {
+		return newValidationError(RejectBadTransaction, "交易%x缺少交易hash或输出", ts.TxHash)
+	}
+	if len(ts.Vint) > maxTxInputs || len(ts.Vout) > maxTxOutputs {
+		return newValidationError(RejectOversize, "交易%x有%d个输入与%d个输出,上限为%d与%d", ts.TxHash, len(ts.Vint), len(ts.Vout), maxTxInputs, maxTxOutputs)
+	}
+	if size := ts.Size(); size > MaxBlockSize-BlockReservedSize {
+		return newValidationError(RejectOversize, "交易%x大小%d字节,超过上限%d", ts.TxHash, size, MaxBlockSize-BlockReservedSize)
+	}
+	outAmount := 0
+	for _, vOut := range ts.Vout {
+		if vOut.Value < 0 {
+			return newValidationError(RejectBadTransaction, "交易%x的输出金额为负数", ts.TxHash)
+		}
+		if vOut.Value > MaxMoney {
+			return newValidationError(RejectBadTransaction, "交易%x的输出金额%d超过上限%d", ts.TxHash, vOut.Value, MaxMoney)
+		}
+		outAmount += vOut.Value
+		if outAmount > MaxMoney {
+			return newValidationError(RejectBadTransaction, "交易%x的输出总额超过上限%d", ts.TxHash, MaxMoney)
+		}
+	}
+	if !ts.VerifyTxHash() {
+		return newValidationError(RejectBadTxHash, "交易%x的交易hash应为%x", ts.TxHash, ts.calcTxHash())
+	}
+	if !ts.IsCoinbase() {
+		for _, vIn := range ts.Vint {
+			if vIn.Index < 0 {
+				return newValidationError(RejectBadTransaction, "交易%x的输入索引不正确", ts.TxHash)
+			}
+		}
+	}
+	return nil
+}
+
+//区块头的上下文校验(难度值、工作量证明等)
+func (bc *blockchain) checkBlockHeader(block *Block) error {
+	err := bc.checkHeader(&block.BlockHeader)
+	if err != nil {
+		return newValidationError(RejectBadHeader, "%s", err)
+	}
+	return nil
+}
+
+//依赖utxo数据库的校验:输入存在且未被花费、数字签名、余额、奖励金额
+func (bc *blockchain) checkBlockContext(block *Block) error {
+	tipHash := bc.BD.View([]byte(utxoTipMapping), database.UndoBucket)
+	if !bytes.Equal(tipHash, block.PreHash) && !(len(tipHash) == 0 && isGenesisBlock(block)) {
+		return newValidationError(RejectNotOnTip, "区块的上一个区块为%x,当前最新区块为%x", block.PreHash, tipHash)
+	}
+	//区块接在最新区块之后,视图时间即为上一个区块的过去中位时间
+	view := newUTXOView(bc, block.Height)
+	fees := 0
+	var coinbase *Transaction
+	for i := range block.Transactions {
+		ts := &block.Transactions[i]
+		if ts.IsCoinbase() {
+			if !ts.IsFinal(view.height, view.timeStamp) {
+				return newValidationError(RejectNonFinal, "奖励交易%x的LockTime为%d", ts.TxHash, ts.LockTime)
+			}
+			coinbase = ts
+		} else {
+			fee, err := bc.checkTransactionInputs(ts, view)
+			if err != nil {
+				return err
+			}
+			fees += fee
+			if fees > MaxMoney {
+				return newValidationError(RejectBadTransaction, "区块的手续费总额超过上限%d", MaxMoney)
+			}
+		}
+		view.addTransaction(ts)
+	}
+	//创世交易是创世区块的奖励交易,不限制金额
+	if coinbase != nil && !isGenesisBlock(block) {
+		reward := 0
+		for _, vOut := range coinbase.Vout {
+			reward += vOut.Value
+		}
+		if limit := GetBlockSubsidy(block.Height) + fees; reward > limit {
+			return newValidationError(RejectBadCoinbase, "奖励交易%x的金额%d超过了出块奖励与手续费之和%d", coinbase.TxHash, reward, limit)
+		}
+	}
+	return nil
+}
+
+//校验交易的输入:已过绝对时间锁、引用的输出存在且未被花费并满足相对时间锁、解锁脚本与锁定脚本执行成功、输入金额不小于输出金额,返回交易手续费
+func (bc *blockchain) checkTransactionInputs(ts *Transaction, view *UTXOView) (int, error) {
+	if len(ts.Vint) == 0 {
+		return 0, newValidationError(RejectBadTransaction, "交易%x没有输入", ts.TxHash)
+	}
+	if !ts.IsFinal(view.height, view.timeStamp) {
+		return 0, newValidationError(RejectNonFinal, "交易%x的LockTime为%d,不能打包进高度%d(过去中位时间%d)的区块", ts.TxHash, ts.LockTime, view.height, view.timeStamp)
+	}
+	inAmount := 0
+	spentInTs := map[string]bool{}
+	for index, vIn := range ts.Vint {
+		key := utxoKey(vIn.TxHash, vIn.Index)
+		if spentInTs[key] || view.isSpent(vIn.TxHash, vIn.Index) {
+			return 0, newValidationError(RejectDoubleSpend, "交易%x的输入%x:%d已被花费", ts.TxHash, vIn.TxHash, vIn.Index)
+		}
+		spentInTs[key] = true
+		utxo := view.lookup(vIn.TxHash, vIn.Index)
+		if utxo == nil {
+			return 0, newValidationError(RejectMissingInputs, "交易%x的输入%x:%d找不到对应的输出", ts.TxHash, vIn.TxHash, vIn.Index)
+		}
+		if !isMatureUTXO(utxo, view.height) {
+			return 0, newValidationError(RejectImmatureCoinbase, "交易%x的输入%x:%d是高度%d的奖励输出,需经过%d个区块才能花费", ts.TxHash, vIn.TxHash, vIn.Index, utxo.Height, ActiveParams.CoinbaseMaturity)
+		}
+		if view.height-utxo.Height < vIn.RelativeLock() {
+			return 0, newValidationError(RejectSequenceLock, "交易%x的输入%x:%d在高度%d被打包,需经过%d个区块才能花费", ts.TxHash, vIn.TxHash, vIn.Index, utxo.Height, vIn.RelativeLock())
+		}
+		if err := ts.verifyInputScript(index, utxo.Vout); err != nil {
+			return 0, newValidationError(RejectBadSignature, "交易%x的第%d个输入没有通过脚本验证:%s", ts.TxHash, index, err)
+		}
+		inAmount += utxo.Vout.Value
+		if utxo.Vout.Value < 0 || utxo.Vout.Value > MaxMoney || inAmount > MaxMoney {
+			return 0, newValidationError(RejectBadTransaction, "交易%x的输入总额超过上限%d", ts.TxHash, MaxMoney)
+		}
+	}
+	outAmount := 0
+	for _, vOut := range ts.Vout {
+		outAmount += vOut.Value
+		if vOut.Value < 0 || vOut.Value > MaxMoney || outAmount > MaxMoney {
+			return 0, newValidationError(RejectBadTransaction, "交易%x的输出总额超过上限%d", ts.TxHash, MaxMoney)
+		}
+	}
+	if outAmount > inAmount {
+		return 0, newValidationError(RejectInsufficientFunds, "交易%x输出金额%d大于输入金额%d", ts.TxHash, outAmount, inAmount)
+	}
+	return inAmount - outAmount, nil
+}
+
+//校验交易时用于查找输出的utxo视图:在utxo数据库的基础上叠加尚未写入数据库的交易
+type UTXOView struct {
+	bc *blockchain
+	//花费视图中输出的区块高度
+	height int
+	//最新区块的过去中位时间,用于校验交易的绝对时间锁
+	timeStamp int64
+	//视图中新增的输出
+	added map[string]*UTXO
+	//视图中已花费的输出
+	spent map[string]bool
+}
+
+func newUTXOView(bc *blockchain, height int) *UTXOView {
+	return &UTXOView{bc, height, bc.tipPastMedianTime(), map[string]*UTXO{}, map[string]bool{}}
+}
+
+//创建用于校验将要打包进高度为height的区块的交易的utxo视图
+func (bc *blockchain) NewUTXOView(height int) *UTXOView {
+	return newUTXOView(bc, height)
+}
+
+func utxoKey(hash []byte, index int) string {
+	return fmt.Sprintf("%x_%d", hash, index)
+}
+
+//查找未花费的输出,找不到或已花费返回nil
+func (v *UTXOView) lookup(hash []byte, index int) *UTXO {
+	key := utxoKey(hash, index)
+	if v.spent[key] {
+		return nil
+	}
+	if utxo, ok := v.added[key]; ok {
+		return utxo
+	}
+	utxoByte := v.bc.BD.View(hash, database.UTXOBucket)
+	if len(utxoByte) == 0 {
+		return nil
+	}
+	u := UTXOHandle{v.bc}
+	for _, utxo := range u.dserialize(utxoByte) {
+		if utxo.Index == index {
+			return utxo
+		}
+	}
+	return nil
+}
+
+func (v *UTXOView) isSpent(hash []byte, index int) bool {
+	return v.spent[utxoKey(hash, index)]
+}
+
+//将尚未打包的交易叠加到视图中,之后校验的交易可以花费它的输出
+func (v *UTXOView) AddTransaction(ts *Transaction) {
+	v.addTransaction(ts)
+}
+
+//校验一笔尚未打包的普通交易:交易格式、输入存在且未被花费、数字签名、余额,返回交易手续费
+func (v *UTXOView) CheckTransaction(ts *Transaction) (int, error) {
+	err := checkTransactionSanity(ts)
+	if err != nil {
+		return 0, err
+	}
+	if ts.IsCoinbase() {
+		return 0, newValidationError(RejectBadCoinbase, "奖励交易%x只能由出块节点打包", ts.TxHash)
+	}
+	return v.bc.checkTransactionInputs(ts, v)
+}
+
+//将交易叠加到视图中:标记其输入已花费,并加入其输出
+func (v *UTXOView) addTransaction(ts *Transaction) {
+	for _, vIn := range ts.Vint {
+		if vIn.Index == -1 {
+			continue
+		}
+		v.spent[utxoKey(vIn.TxHash, vIn.Index)] = true
+	}
+	for index, vOut := range ts.Vout {
+		v.added[utxoKey(ts.TxHash, index)] = &UTXO{ts.TxHash, index, vOut, v.height, ts.IsCoinbase()}
+	}
+}
@@ -3,9 +3,7 @@ package cli
 import (
 	"bufio"
 	"fmt"
-	log "github.com/corgi-kx/logcustom"
 	"os"
-	"strconv"
 	"strings"
 )
 
@@ -17,15 +15,26 @@ func printUsage() {
 	fmt.Println("----------------------------------------------------------------------------- ")
 	fmt.Println("Usage:")
 	fmt.Println("\thelp                                              打印命令行说明")
-	fmt.Println("\tgenesis  -a DATA  -v DATA                         生成创世区块")
 	fmt.Println("\tsetRewardAddr -a DATA                             设置挖矿奖励地址")
 	fmt.Println("\tgenerateWallet                                    创建新钱包")
 	fmt.Println("\timportMnword -m DATA                              根据助记词导入钱包")
 	fmt.Println("\tprintAllWallets                                   查看本地存在的钱包信息")
 	fmt.Println("\tprintAllAddr                                      查看本地存在的地址信息")
 	fmt.Println("\tgetBalance  -a DATA                               查看用户余额")
-	fmt.Println("\ttransfer -from DATA -to DATA -amount DATA         进行转账操作")
+	fmt.Println("\ttransfer -from DATA -to DATA -amount DATA [-fee DATA] [-sequence DATA] [-locktime DATA] 进行转账操作(可附带手续费、相对时间锁与时间锁)")
+	fmt.Println("\tbumpFee -h DATA -fee DATA                         提高交易池中尚未打包交易的手续费(替换原交易)")
+	fmt.Println("\tsendRawTx -tx DATA                                广播十六进制编码的已签名交易")
+	fmt.Println("\tcreateMultisig -m DATA -k DATA                    由m与逗号分隔的公钥创建多重签名地址")
+	fmt.Println("\tcreateMultisigTx -r DATA -to DATA -amount DATA [-fee DATA] 创建花费多重签名地址的待签名交易")
+	fmt.Println("\tsignMultisigTx -a DATA -tx DATA                   使用本地地址的私钥对多重签名交易签名")
+	fmt.Println("\tcombineMultisigTx -tx DATA                        合并各方签名的交易(逗号分隔)")
+	fmt.Println("\tsendMultisigTx -tx DATA                           广播签名数量已满足要求的多重签名交易")
 	fmt.Println("\tprintAllBlock                                     查看所有区块信息")
+	fmt.Println("\tgetTxProof -h DATA                                获取交易的默克尔证明")
+	fmt.Println("\tgetMiningInfo                                     查看共识类型与挖矿算力")
+	fmt.Println("\tstartMining                                       开启挖矿")
+	fmt.Println("\tstopMining                                        关闭挖矿")
+	fmt.Println("\tgetPoolShares                                     查看Stratum矿池中各矿工的份额")
 	fmt.Println("\tresetUTXODB                                       遍历区块数据，重置UTXO数据库")
 	fmt.Println("------------------------------------------------------------------------------")
 }
@@ -69,14 +78,6 @@ func (cli Cli) userCmdHandle(data string) {
 	switch cmd {
 	case "help":
 		printUsage()
-	case "genesis":
-		address := getSpecifiedContent(data, "-a", "-v")
-		value := getSpecifiedContent(data, "-v", "")
-		v, err := strconv.Atoi(value)
-		if err != nil {
-			log.Fatal(err)
-		}
-		cli.genesis(address, v)
 	case "generateWallet":
 		cli.generateWallet()
 	case "setRewardAddr":
@@ -94,13 +95,74 @@ func (cli Cli) userCmdHandle(data string) {
 	case "getBalance":
 		address := getSpecifiedContent(data, "-a", "")
 		cli.getBalance(address)
+	case "getTxProof":
+		txHash := getSpecifiedContent(data, "-h", "")
+		cli.getTxProof(txHash)
+	case "getMiningInfo":
+		cli.getMiningInfo()
+	case "startMining":
+		cli.startMining()
+	case "stopMining":
+		cli.stopMining()
+	case "getPoolShares":
+		cli.getPoolShares()
 	case "resetUTXODB":
 		cli.resetUTXODB()
+	case "createMultisig":
+		m := getSpecifiedContent(data, "-m", "-k")
+		keys := getSpecifiedContent(data, "-k", "")
+		cli.createMultisig(m, keys)
+	case "createMultisigTx":
+		redeem := getSpecifiedContent(data, "-r", "-to")
+		to := getSpecifiedContent(data, "-to", "-amount")
+		//手续费为可选参数
+		fee := ""
+		amount := getSpecifiedContent(data, "-amount", "")
+		if strings.Contains(data, "-fee") {
+			amount = getSpecifiedContent(data, "-amount", "-fee")
+			fee = getSpecifiedContent(data, "-fee", "")
+		}
+		cli.createMultisigTx(redeem, to, amount, fee)
+	case "signMultisigTx":
+		address := getSpecifiedContent(data, "-a", "-tx")
+		tx := getSpecifiedContent(data, "-tx", "")
+		cli.signMultisigTx(address, tx)
+	case "combineMultisigTx":
+		tx := getSpecifiedContent(data, "-tx", "")
+		cli.combineMultisigTx(tx)
+	case "sendMultisigTx":
+		tx := getSpecifiedContent(data, "-tx", "")
+		cli.sendMultisigTx(tx)
+	case "bumpFee":
+		txHash := getSpecifiedContent(data, "-h", "-fee")
+		fee := getSpecifiedContent(data, "-fee", "")
+		cli.bumpFee(txHash, fee)
+	case "sendRawTx":
+		tx := getSpecifiedContent(data, "-tx", "")
+		cli.sendRawTx(tx)
 	case "transfer":
+		//时间锁为可选参数,放在最后
+		lockTimeString := ""
+		if strings.Contains(context, "-locktime") {
+			lockTimeString = strings.TrimSpace(context[strings.Index(context, "-locktime")+len("-locktime"):])
+			context = context[:strings.Index(context, "-locktime")]
+		}
+		//相对时间锁为可选参数,放在手续费之后
+		sequenceString := ""
+		if strings.Contains(context, "-sequence") {
+			sequenceString = strings.TrimSpace(context[strings.Index(context, "-sequence")+len("-sequence"):])
+			context = context[:strings.Index(context, "-sequence")]
+		}
 		fromString := (context[strings.Index(context, "-from")+len("-from") : strings.Index(context, "-to")])
 		toString := strings.TrimSpace(context[strings.Index(context, "-to")+len("-to") : strings.Index(context, "-amount")])
+		//手续费为可选参数
+		feeString := ""
 		amountString := strings.TrimSpace(context[strings.Index(context, "-amount")+len("-amount"):])
-		cli.transfer(fromString, toString, amountString)
+		if strings.Contains(context, "-fee") {
+			amountString = strings.TrimSpace(context[strings.Index(context, "-amount")+len("-amount") : strings.Index(context, "-fee")])
+			feeString = strings.TrimSpace(context[strings.Index(context, "-fee")+len("-fee"):])
+		}
+		cli.transfer(fromString, toString, amountString, feeString, lockTimeString, sequenceString)
 	default:
 		fmt.Println("无此命令!")
 		printUsage()
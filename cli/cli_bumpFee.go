@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/network"
+	log "github.com/corgi-kx/logcustom"
+	"strconv"
+)
+
+//为交易池中尚未打包的交易生成手续费更高的替换交易并广播
+func (cli *Cli) bumpFee(txHash, fee string) {
+	hash, err := hex.DecodeString(txHash)
+	if err != nil {
+		log.Error("交易hash格式不正确：", err)
+		return
+	}
+	newFee, err := strconv.Atoi(fee)
+	if err != nil {
+		log.Errorf("手续费格式不正确:%s", fee)
+		return
+	}
+	ts, ok := network.GetPoolTransaction(hash)
+	if !ok {
+		log.Errorf("交易池中没有交易%x,可能已被打包或替换", hash)
+		return
+	}
+	bc := block.NewBlockchain()
+	newTs, err := bc.BumpFee(ts, newFee)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	network.Send{}.SendTransToPeers([]block.Transaction{*newTs})
+	fmt.Printf("已广播替换交易%x,原交易%x\n", newTs.TxHash, hash)
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+	"strings"
+)
+
+//合并各方签名后的多重签名交易(逗号分隔)
+func (cli *Cli) combineMultisigTx(data string) {
+	ps := []*block.PartialTransaction{}
+	for _, d := range strings.Split(data, ",") {
+		p, err := block.DeserializePartialTransaction(strings.TrimSpace(d))
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		ps = append(ps, p)
+	}
+	p, err := block.CombinePartialTransactions(ps)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if p.IsComplete() {
+		fmt.Println("签名数量已满足要求,可以广播交易")
+	} else {
+		fmt.Println("签名数量还不满足要求,需要继续签名")
+	}
+	fmt.Println("合并后的交易：", p.Serialize())
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+	"strconv"
+	"strings"
+)
+
+//由m与逗号分隔的十六进制公钥列表创建m-of-n多重签名地址
+func (cli *Cli) createMultisig(m, keys string) {
+	required, err := strconv.Atoi(m)
+	if err != nil {
+		log.Error("签名数量必须为整数")
+		return
+	}
+	publicKeys := [][]byte{}
+	for _, k := range strings.Split(keys, ",") {
+		publicKey, err := hex.DecodeString(strings.TrimSpace(k))
+		if err != nil {
+			log.Errorf("公钥%s不是十六进制编码", k)
+			return
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	address, redeemScript, err := block.CreateMultisigAddress(required, publicKeys)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	fmt.Println("多重签名地址：", address)
+	fmt.Printf("赎回脚本：%x\n", redeemScript)
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+	"strconv"
+)
+
+//创建花费多重签名地址余额的待签名交易,输出的交易数据交给各方签名
+func (cli *Cli) createMultisigTx(redeem, to, amount, fee string) {
+	redeemScript, err := hex.DecodeString(redeem)
+	if err != nil {
+		log.Error("赎回脚本不是十六进制编码")
+		return
+	}
+	value, err := strconv.Atoi(amount)
+	if err != nil {
+		log.Error("转账金额必须为整数")
+		return
+	}
+	feeValue := 0
+	if fee != "" {
+		feeValue, err = strconv.Atoi(fee)
+		if err != nil {
+			log.Error("手续费必须为整数")
+			return
+		}
+	}
+	bc := block.NewBlockchain()
+	p, err := bc.CreateMultisigTransaction(redeemScript, to, value, feeValue)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	fmt.Printf("交易hash：%x\n", p.Tx.TxHash)
+	fmt.Println("待签名交易：", p.Serialize())
+}
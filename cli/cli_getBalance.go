@@ -8,5 +8,6 @@ import (
 func (cli *Cli) getBalance(address string) {
 	bc := block.NewBlockchain()
 	balance := bc.GetBalance(address)
-	fmt.Printf("地址:%s的余额为：%d\n", address, balance)
+	spendable := bc.GetSpendableBalance(address)
+	fmt.Printf("地址:%s的余额为：%d(其中可用余额为：%d)\n", address, balance, spendable)
 }
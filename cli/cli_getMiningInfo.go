@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/network"
+)
+
+func (cli *Cli) getMiningInfo() {
+	bc := block.NewBlockchain()
+	fmt.Printf("共识类型         %s\n", block.Engine.Name())
+	fmt.Printf("是否开启挖矿     %t\n", network.IsMining())
+	fmt.Printf("出块间隔         %ds\n", network.BlockInterval)
+	fmt.Printf("最新区块高度     %d\n", bc.GetLastBlockHeight())
+	fmt.Printf("挖矿线程数       %d\n", block.GetMinerThreads())
+	fmt.Printf("挖矿算力         %.0f H/s\n", block.GetHashRate())
+}
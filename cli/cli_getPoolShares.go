@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/network"
+	"sort"
+)
+
+func (cli *Cli) getPoolShares() {
+	shares := network.GetWorkerShares()
+	if len(shares) == 0 {
+		fmt.Println("矿池中还没有矿工提交份额")
+		return
+	}
+	workers := []string{}
+	totalWork := 0.0
+	for k, v := range shares {
+		workers = append(workers, k)
+		totalWork += v.Work
+	}
+	sort.Strings(workers)
+	fmt.Println("矿工                 有效份额   无效份额   挖到区块   工作量占比")
+	for _, w := range workers {
+		s := shares[w]
+		ratio := 0.0
+		if totalWork > 0 {
+			ratio = s.Work / totalWork * 100
+		}
+		fmt.Printf("%-20s %-10d %-10d %-10d %.2f%%\n", w, s.Accepted, s.Rejected, s.Blocks, ratio)
+	}
+}
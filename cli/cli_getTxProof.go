@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+)
+
+func (cli *Cli) getTxProof(txHash string) {
+	hash, err := hex.DecodeString(txHash)
+	if err != nil {
+		log.Error("交易hash格式不正确：", err)
+		return
+	}
+	bc := block.NewBlockchain()
+	proof, err := bc.GetTxProof(hash)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !proof.Verify() {
+		log.Error("生成的默克尔证明没有通过验证")
+		return
+	}
+	fmt.Print(proof)
+}
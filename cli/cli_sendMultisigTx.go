@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/network"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//由收集到的签名生成解锁脚本,并将多重签名交易广播到网络中
+func (cli *Cli) sendMultisigTx(data string) {
+	p, err := block.DeserializePartialTransaction(data)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	ts, err := p.Finalize()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	network.Send{}.SendTransToPeers([]block.Transaction{*ts})
+	fmt.Printf("已广播交易%x\n", ts.TxHash)
+}
@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/network"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//将十六进制编码的已签名交易广播到网络中(例如到期后的时间锁交易)
+func (cli *Cli) sendRawTx(data string) {
+	ts, err := block.DeserializeTransaction(data)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	network.Send{}.SendTransToPeers([]block.Transaction{*ts})
+	fmt.Printf("已广播交易%x\n", ts.TxHash)
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//使用本地钱包中地址的私钥对多重签名交易进行签名
+func (cli *Cli) signMultisigTx(address, data string) {
+	p, err := block.DeserializePartialTransaction(data)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	bc := block.NewBlockchain()
+	n, err := bc.SignPartialTransaction(p, address)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	fmt.Printf("已使用地址%s对%d个输入签名\n", address, n)
+	fmt.Println("已签名交易：", p.Serialize())
+}
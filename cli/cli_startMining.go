@@ -0,0 +1,11 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/network"
+)
+
+func (cli *Cli) startMining() {
+	network.SetMining(true)
+	fmt.Println("已开启挖矿")
+}
@@ -0,0 +1,12 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/corgi-kx/blockchain_golang/network"
+)
+
+//关闭挖矿并终止正在进行的挖矿
+func (cli *Cli) stopMining() {
+	network.SetMining(false)
+	fmt.Println("已关闭挖矿")
+}
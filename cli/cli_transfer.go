@@ -6,8 +6,8 @@ import (
 	"github.com/corgi-kx/blockchain_golang/network"
 )
 
-func (cli Cli) transfer(from, to, amount string) {
+func (cli Cli) transfer(from, to, amount, fee, lockTime, sequence string) {
 	blc := block.NewBlockchain()
-	blc.CreateTransaction(from, to, amount, network.Send{})
+	blc.CreateTransaction(from, to, amount, fee, lockTime, sequence, network.Send{})
 	fmt.Println("已执行转帐命令")
 }
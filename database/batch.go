@@ -0,0 +1,87 @@
+/*
+	本包是作为对blot数据库封装的一个存在
+*/
+package database
+
+import (
+	"github.com/boltdb/bolt"
+	log "github.com/corgi-kx/logcustom"
+)
+
+//一组待提交的修改:读取时优先返回尚未提交的修改,Commit时在同一个bolt事务中写入,
+//要么全部生效,要么全部不生效,避免中途崩溃时数据库处于修改了一半的状态
+type Batch struct {
+	bd *BlockchainDB
+	//按修改顺序记录的操作
+	ops []batchOp
+	//尚未提交的数据 key:仓库名+数据键  value为nil表示已删除
+	pending map[string][]byte
+}
+
+type batchOp struct {
+	k, v   []byte
+	bt     BucketType
+	delete bool
+}
+
+//创建一组待提交的修改
+func (bd *BlockchainDB) NewBatch() *Batch {
+	return &Batch{bd, []batchOp{}, map[string][]byte{}}
+}
+
+func pendingKey(k []byte, bt BucketType) string {
+	return string(bt) + "/" + string(k)
+}
+
+//存入数据(提交前只对本组修改可见)
+func (b *Batch) Put(k, v []byte, bt BucketType) {
+	b.ops = append(b.ops, batchOp{k, v, bt, false})
+	b.pending[pendingKey(k, bt)] = v
+}
+
+//删除数据(提交前只对本组修改可见)
+func (b *Batch) Delete(k []byte, bt BucketType) {
+	b.ops = append(b.ops, batchOp{k, nil, bt, true})
+	b.pending[pendingKey(k, bt)] = nil
+}
+
+//查看数据,本组中已修改的数据返回修改后的值
+func (b *Batch) View(k []byte, bt BucketType) []byte {
+	if v, ok := b.pending[pendingKey(k, bt)]; ok {
+		return v
+	}
+	return b.bd.View(k, bt)
+}
+
+//在同一个事务中提交全部修改
+func (b *Batch) Commit() {
+	var DBFileName = "blockchain_" + ListenPort + ".db"
+	db, err := bolt.Open(DBFileName, 0600, nil)
+	defer db.Close()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, op := range b.ops {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(op.bt))
+			if err != nil {
+				return err
+			}
+			if op.delete {
+				err = bucket.Delete(op.k)
+			} else {
+				err = bucket.Put(op.k, op.v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	b.ops = []batchOp{}
+	b.pending = map[string][]byte{}
+}
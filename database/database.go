@@ -15,9 +15,14 @@ var ListenPort string
 type BucketType string
 
 const (
-	BlockBucket BucketType = "blocks"
-	AddrBucket  BucketType = "address"
-	UTXOBucket  BucketType = "utxo"
+	BlockBucket   BucketType = "blocks"
+	AddrBucket    BucketType = "address"
+	UTXOBucket    BucketType = "utxo"
+	WorkBucket    BucketType = "work"
+	UndoBucket    BucketType = "undo"
+	HeaderBucket  BucketType = "headers"
+	MempoolBucket BucketType = "mempool"
+	HeightBucket  BucketType = "height"
 )
 
 type BlockchainDB struct {
@@ -115,3 +115,31 @@ func (bd *BlockchainDB) DeleteBucket(bt BucketType) bool {
 
 	return true
 }
+
+//遍历仓库中的全部数据,仓库不存在时直接返回
+func (bd *BlockchainDB) ForEach(bt BucketType, fn func(k, v []byte)) {
+	var DBFileName = "blockchain_" + ListenPort + ".db"
+	db, err := bolt.Open(DBFileName, 0600, nil)
+	defer db.Close()
+	if err != nil {
+		log.Panic(err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bt))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			//bolt中的数据只在事务内有效,需要拷贝一份
+			realK := make([]byte, len(k))
+			copy(realK, k)
+			realV := make([]byte, len(v))
+			copy(realV, v)
+			fn(realK, realV)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	block "github.com/corgi-kx/blockchain_golang/blc"
 	"github.com/corgi-kx/blockchain_golang/cli"
 	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/mempool"
 	"github.com/corgi-kx/blockchain_golang/network"
 	log "github.com/corgi-kx/logcustom"
 	"github.com/spf13/viper"
@@ -22,23 +23,50 @@ func init() {
 	logPath := viper.GetString("blockchain.log_path")
 	listenHost := viper.GetString("network.listen_host")
 	listenPort := viper.GetString("network.listen_port")
-	rendezvousString := viper.GetString("network.rendezvous_string")
-	protocolID := viper.GetString("network.protocol_id")
-	tokenRewardNum := viper.GetInt("blockchain.token_reward_num")
-	tradePoolLength := viper.GetInt("blockchain.trade_pool_length")
-	mineDifficultyValue := viper.GetInt("blockchain.mine_difficulty_value")
+	rpcListen := viper.GetString("network.rpc_listen")
+	stratumListen := viper.GetString("network.stratum_listen")
+	stratumShareDifficulty := viper.GetFloat64("network.stratum_share_difficulty")
+	stratumJobInterval := viper.GetInt("network.stratum_job_interval")
+	blockInterval := viper.GetInt64("blockchain.block_interval")
+	miningEnabled := viper.GetBool("blockchain.mining_enabled")
+	mempoolMaxCount := viper.GetInt("blockchain.mempool_max_count")
+	mempoolMaxSize := viper.GetInt("blockchain.mempool_max_size")
+	mempoolExpiry := viper.GetInt64("blockchain.mempool_expiry")
+	blockMaxSize := viper.GetInt("blockchain.block_max_size")
+	rebroadcastInterval := viper.GetInt("blockchain.rebroadcast_interval")
+	minerThreads := viper.GetInt("blockchain.miner_threads")
 	chineseMnwordPath := viper.GetString("blockchain.chinese_mnemonic_path")
+	chainNetwork := viper.GetString("blockchain.network")
+	consensus := viper.GetString("blockchain.consensus")
+	poaValidators := viper.GetStringSlice("blockchain.poa_validators")
 
-	network.TradePoolLength = tradePoolLength
+	network.BlockInterval = blockInterval
+	network.SetMining(miningEnabled)
+	mempool.MaxPoolCount = mempoolMaxCount
+	mempool.MaxPoolSize = mempoolMaxSize
+	mempool.Expiry = mempoolExpiry
+	if blockMaxSize > 0 && blockMaxSize < mempool.BlockMaxSize {
+		mempool.BlockMaxSize = blockMaxSize
+	}
+	network.RebroadcastInterval = rebroadcastInterval
 	network.ListenHost = listenHost
-	network.RendezvousString = rendezvousString
-	network.ProtocolID = protocolID
+	network.RPCListen = rpcListen
+	network.StratumListen = stratumListen
+	network.StratumShareDifficulty = stratumShareDifficulty
+	network.StratumJobInterval = stratumJobInterval
 	network.ListenPort = listenPort
 	database.ListenPort = listenPort
 	block.ListenPort = listenPort
-	block.TokenRewardNum = tokenRewardNum
-	block.TargetBits = uint(mineDifficultyValue)
+	block.MinerThreads = minerThreads
 	block.ChineseMnwordPath = chineseMnwordPath
+	err = block.SetChainParams(chainNetwork)
+	if err != nil {
+		panic(err)
+	}
+	err = block.SetConsensusEngine(consensus, poaValidators)
+	if err != nil {
+		panic(err)
+	}
 
 	//将日志输出到指定文件
 	file, err := os.OpenFile(fmt.Sprintf("%slog%s.txt", logPath, listenPort), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
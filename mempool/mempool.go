@@ -0,0 +1,412 @@
+/*
+	交易池:接收到交易时先校验交易格式、数字签名与utxo,通过后才放入交易池,
+	允许花费交易池中尚未打包的交易输出,打包区块时按交易包(交易加上尚未选取的祖先交易)的手续费率从高到低选取交易
+	与交易池中交易冲突(花费同一个输出)的新交易,只有在原交易声明可替换且新交易手续费更高时才能替换原交易(RBF)
+*/
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/database"
+	log "github.com/corgi-kx/logcustom"
+	"sync"
+	"time"
+)
+
+//交易池中最多存放的交易数量
+var MaxPoolCount = 5000
+
+//交易池中交易的总大小上限(字节)
+var MaxPoolSize = 5000000
+
+//打包区块时选取交易的总大小上限(字节),不能超过共识规则中区块留给普通交易的空间
+var BlockMaxSize = block.MaxBlockSize - block.BlockReservedSize
+
+//交易在交易池中最多停留的时间(秒),超时仍未打包则移出交易池
+var Expiry int64 = 72 * 60 * 60
+
+//一笔替换交易最多能移出的交易数量(包括冲突交易的后代交易)
+var MaxReplacementEvictions = 100
+
+//交易池中的交易及其附加信息
+type TxDesc struct {
+	Tx block.Transaction
+	//交易手续费
+	Fee int
+	//交易大小(字节)
+	Size int
+	//进入交易池的时间
+	Added int64
+}
+
+//比较两笔交易的手续费率(手续费/交易大小),a的手续费率更高时返回true
+func higherFeeRate(a, b *TxDesc) bool {
+	return a.Fee*b.Size > b.Fee*a.Size
+}
+
+type Mempool struct {
+	lock sync.RWMutex
+	//key:交易hash value:交易信息
+	pool map[string]*TxDesc
+	//key:被花费的输出 value:花费该输出的交易hash
+	spent map[string][]byte
+	//交易池中交易的总大小
+	totalSize int
+	//用于持久化交易池的数据库,为nil时不进行持久化
+	bd *database.BlockchainDB
+	//孤儿交易池 key:交易hash
+	orphans map[string]*orphanTx
+	//key:孤儿交易缺失的父交易hash value:孤儿交易hash的集合
+	orphansByPrev map[string]map[string]bool
+}
+
+func NewMempool(bd *database.BlockchainDB) *Mempool {
+	return &Mempool{
+		pool:          map[string]*TxDesc{},
+		spent:         map[string][]byte{},
+		bd:            bd,
+		orphans:       map[string]*orphanTx{},
+		orphansByPrev: map[string]map[string]bool{},
+	}
+}
+
+func outpointKey(hash []byte, index int) string {
+	return fmt.Sprintf("%x_%d", hash, index)
+}
+
+//校验交易并加入交易池,引用的输出尚不存在的交易会存入孤儿交易池,等父交易到达后再处理
+func (mp *Mempool) Add(ts block.Transaction) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	err := mp.add(ts, time.Now().Unix())
+	if isMissingInputs(err) {
+		return mp.addOrphan(ts)
+	}
+	if err != nil {
+		return err
+	}
+	mp.processOrphans(ts.TxHash)
+	return nil
+}
+
+//校验交易并加入交易池,added为交易进入交易池的时间
+func (mp *Mempool) add(ts block.Transaction, added int64) error {
+	if _, ok := mp.pool[string(ts.TxHash)]; ok {
+		return fmt.Errorf("Add err : 交易%x已存在于交易池中", ts.TxHash)
+	}
+	//交易池中的交易不能花费同一个输出,冲突的交易只能通过替换规则替换
+	replaced, err := mp.conflicts(&ts)
+	if err != nil {
+		return err
+	}
+	//在utxo数据库的基础上叠加交易池中的父交易,允许花费尚未打包的输出
+	bc := block.NewBlockchain()
+	view := bc.NewUTXOView(bc.GetLastBlockHeight() + 1)
+	for _, parent := range mp.parents(&ts) {
+		if _, ok := replaced[string(parent.Tx.TxHash)]; ok {
+			return fmt.Errorf("Add err : 交易%x花费了将被它替换的交易%x的输出", ts.TxHash, parent.Tx.TxHash)
+		}
+		view.AddTransaction(&parent.Tx)
+	}
+	fee, err := view.CheckTransaction(&ts)
+	if err != nil {
+		return err
+	}
+	desc := &TxDesc{ts, fee, ts.Size(), added}
+	//替换交易的手续费必须高于被移出的全部交易的手续费之和
+	replacedFee := 0
+	for _, d := range replaced {
+		replacedFee += d.Fee
+	}
+	if len(replaced) > 0 && fee <= replacedFee {
+		return fmt.Errorf("Add err : 交易%x的手续费%d不高于将被替换的%d笔交易的手续费之和%d", ts.TxHash, fee, len(replaced), replacedFee)
+	}
+	for hash := range replaced {
+		mp.remove([]byte(hash), false)
+	}
+	err = mp.makeRoom(desc)
+	if err != nil {
+		//交易池空间不足时恢复被替换的交易
+		for _, d := range replaced {
+			mp.insert(d)
+		}
+		return err
+	}
+	mp.insert(desc)
+	for _, d := range replaced {
+		log.Infof("交易%x已被手续费更高的交易%x替换", d.Tx.TxHash, ts.TxHash)
+	}
+	log.Debugf("交易%x已加入交易池,手续费%d,大小%d字节", ts.TxHash, fee, desc.Size)
+	return nil
+}
+
+//获取与交易冲突(花费同一个输出)的交易池中的交易以及它们的后代交易,即交易加入后需要移出的交易
+//冲突交易都必须声明可替换,否则返回错误
+func (mp *Mempool) conflicts(ts *block.Transaction) (map[string]*TxDesc, error) {
+	replaced := map[string]*TxDesc{}
+	for _, vIn := range ts.Vint {
+		spender, ok := mp.spent[outpointKey(vIn.TxHash, vIn.Index)]
+		if !ok {
+			continue
+		}
+		conflict := mp.pool[string(spender)]
+		if !conflict.Tx.IsReplaceable() {
+			return nil, fmt.Errorf("Add err : 交易%x的输入%x:%d已被交易池中不可替换的交易%x花费", ts.TxHash, vIn.TxHash, vIn.Index, spender)
+		}
+		for k, v := range mp.descendants(conflict) {
+			replaced[k] = v
+		}
+	}
+	if len(replaced) > MaxReplacementEvictions {
+		return nil, fmt.Errorf("Add err : 交易%x将替换%d笔交易,超过上限%d", ts.TxHash, len(replaced), MaxReplacementEvictions)
+	}
+	return replaced, nil
+}
+
+func (mp *Mempool) insert(desc *TxDesc) {
+	mp.pool[string(desc.Tx.TxHash)] = desc
+	for _, vIn := range desc.Tx.Vint {
+		mp.spent[outpointKey(vIn.TxHash, vIn.Index)] = desc.Tx.TxHash
+	}
+	mp.totalSize += desc.Size
+	if mp.bd != nil {
+		mp.bd.Put(desc.Tx.TxHash, desc.serialize(), database.MempoolBucket)
+	}
+}
+
+//将交易移出交易池,withDescendants为true时同时移出花费其输出的后代交易
+func (mp *Mempool) remove(hash []byte, withDescendants bool) {
+	desc, ok := mp.pool[string(hash)]
+	if !ok {
+		return
+	}
+	if withDescendants {
+		for _, child := range mp.children(desc) {
+			mp.remove(child.Tx.TxHash, true)
+		}
+	}
+	for _, vIn := range desc.Tx.Vint {
+		delete(mp.spent, outpointKey(vIn.TxHash, vIn.Index))
+	}
+	delete(mp.pool, string(hash))
+	mp.totalSize -= desc.Size
+	if mp.bd != nil {
+		mp.bd.Delete(hash, database.MempoolBucket)
+	}
+}
+
+//获取交易在交易池中的父交易
+func (mp *Mempool) parents(ts *block.Transaction) []*TxDesc {
+	parents := []*TxDesc{}
+	seen := map[string]bool{}
+	for _, vIn := range ts.Vint {
+		if parent, ok := mp.pool[string(vIn.TxHash)]; ok && !seen[string(vIn.TxHash)] {
+			seen[string(vIn.TxHash)] = true
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+//获取花费该交易输出的子交易
+func (mp *Mempool) children(desc *TxDesc) []*TxDesc {
+	children := []*TxDesc{}
+	for index := range desc.Tx.Vout {
+		if spender, ok := mp.spent[outpointKey(desc.Tx.TxHash, index)]; ok {
+			if child, ok := mp.pool[string(spender)]; ok {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+//获取该交易以及它在交易池中的全部后代交易
+func (mp *Mempool) descendants(desc *TxDesc) map[string]*TxDesc {
+	result := map[string]*TxDesc{string(desc.Tx.TxHash): desc}
+	for _, child := range mp.children(desc) {
+		for k, v := range mp.descendants(child) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+//交易池容量不足时,驱逐手续费率最低的交易(连同后代交易),直到能够放入新交易
+func (mp *Mempool) makeRoom(desc *TxDesc) error {
+	for len(mp.pool)+1 > MaxPoolCount || mp.totalSize+desc.Size > MaxPoolSize {
+		var lowest *TxDesc
+		for _, d := range mp.pool {
+			if lowest == nil || higherFeeRate(lowest, d) {
+				lowest = d
+			}
+		}
+		if lowest == nil || !higherFeeRate(desc, lowest) {
+			return errors.New("Add err : 交易池已满,且交易的手续费率不高于交易池中的最低手续费率")
+		}
+		//新交易依赖的父交易不能被驱逐
+		victims := mp.descendants(lowest)
+		for _, parent := range mp.parents(&desc.Tx) {
+			if _, ok := victims[string(parent.Tx.TxHash)]; ok {
+				return errors.New("Add err : 交易池已满,且交易依赖的父交易手续费率过低")
+			}
+		}
+		log.Debugf("交易池已满,驱逐手续费率最低的交易%x", lowest.Tx.TxHash)
+		mp.remove(lowest.Tx.TxHash, true)
+	}
+	return nil
+}
+
+//交易与它尚未被选取的祖先交易组成的交易包,打包区块时按整个包的手续费率选取(子交易为父交易支付手续费,CPFP)
+type txPackage struct {
+	//包中的交易,父交易排在子交易之前,最后一笔为交易本身
+	txs  []*TxDesc
+	fee  int
+	size int
+}
+
+//获取交易desc在剔除已选取交易后的交易包
+func (mp *Mempool) ancestorPackage(desc *TxDesc, selected map[string]bool) *txPackage {
+	pkg := &txPackage{}
+	visited := map[string]bool{}
+	var visit func(d *TxDesc)
+	visit = func(d *TxDesc) {
+		if visited[string(d.Tx.TxHash)] || selected[string(d.Tx.TxHash)] {
+			return
+		}
+		visited[string(d.Tx.TxHash)] = true
+		for _, parent := range mp.parents(&d.Tx) {
+			visit(parent)
+		}
+		pkg.txs = append(pkg.txs, d)
+		pkg.fee += d.Fee
+		pkg.size += d.Size
+	}
+	visit(desc)
+	return pkg
+}
+
+//a的交易包是否应当先于b的交易包选取:手续费率更高的优先,相同时先进入交易池的优先
+func (a *txPackage) better(b *txPackage) bool {
+	if a.fee*b.size != b.fee*a.size {
+		return a.fee*b.size > b.fee*a.size
+	}
+	tipA, tipB := a.txs[len(a.txs)-1], b.txs[len(b.txs)-1]
+	if tipA.Added != tipB.Added {
+		return tipA.Added < tipB.Added
+	}
+	return string(tipA.Tx.TxHash) < string(tipB.Tx.TxHash)
+}
+
+//按交易包的手续费率从高到低选取交易用于打包区块,父交易总是排在子交易之前,maxSize为交易总大小上限(小于等于0时不限制)
+//手续费率高的子交易会带着手续费率低的父交易一起被选取
+func (mp *Mempool) BlockTemplate(maxSize int) []block.Transaction {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	selected := map[string]bool{}
+	packages := map[string]*txPackage{}
+	for k, d := range mp.pool {
+		packages[k] = mp.ancestorPackage(d, selected)
+	}
+	tss := []block.Transaction{}
+	size := 0
+	for len(packages) > 0 {
+		var best string
+		for k, pkg := range packages {
+			if best == "" || pkg.better(packages[best]) {
+				best = k
+			}
+		}
+		pkg := packages[best]
+		delete(packages, best)
+		//放不下的交易包跳过,它的后代交易所在的包更大,同样放不下
+		if maxSize > 0 && size+pkg.size > maxSize {
+			continue
+		}
+		for _, d := range pkg.txs {
+			selected[string(d.Tx.TxHash)] = true
+			delete(packages, string(d.Tx.TxHash))
+			tss = append(tss, d.Tx)
+			size += d.Size
+		}
+		//祖先交易被选取后,重新计算后代交易的交易包
+		for _, d := range pkg.txs {
+			for k, child := range mp.descendants(d) {
+				if _, ok := packages[k]; ok {
+					packages[k] = mp.ancestorPackage(child, selected)
+				}
+			}
+		}
+	}
+	return tss
+}
+
+//区块接入主链后,移出已打包的交易以及与之冲突的交易
+func (mp *Mempool) BlockConnected(b *block.Block) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	for _, ts := range b.Transactions {
+		mp.remove(ts.TxHash, false)
+		mp.removeOrphan(ts.TxHash)
+		for _, vIn := range ts.Vint {
+			if spender, ok := mp.spent[outpointKey(vIn.TxHash, vIn.Index)]; ok {
+				log.Debugf("交易%x与区块中的交易%x冲突,移出交易池", spender, ts.TxHash)
+				mp.remove(spender, true)
+			}
+		}
+	}
+	//父交易被直接打包进区块的孤儿交易
+	for _, ts := range b.Transactions {
+		mp.processOrphans(ts.TxHash)
+	}
+}
+
+//区块从主链断开后,将其中的交易重新放回交易池
+func (mp *Mempool) BlockDisconnected(b *block.Block) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	for _, ts := range b.Transactions {
+		if ts.IsCoinbase() {
+			continue
+		}
+		err := mp.add(ts, time.Now().Unix())
+		if err != nil {
+			log.Debugf("断开区块中的交易%x未能放回交易池:%s", ts.TxHash, err)
+		}
+	}
+}
+
+//获取交易池中的交易
+func (mp *Mempool) Get(hash []byte) (block.Transaction, bool) {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	desc, ok := mp.pool[string(hash)]
+	if !ok {
+		return block.Transaction{}, false
+	}
+	return desc.Tx, true
+}
+
+//交易池中是否存在该交易
+func (mp *Mempool) Has(hash []byte) bool {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	_, ok := mp.pool[string(hash)]
+	return ok
+}
+
+//交易池中的交易数量
+func (mp *Mempool) Count() int {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	return len(mp.pool)
+}
+
+//交易池中交易的总大小
+func (mp *Mempool) Size() int {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	return mp.totalSize
+}
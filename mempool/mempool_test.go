@@ -0,0 +1,137 @@
+package mempool
+
+import (
+	"bytes"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"testing"
+)
+
+//构造一笔花费parent第0个输出的交易(不经过校验直接放入交易池)
+func newTestDesc(hash, parent []byte, fee, size int) *TxDesc {
+	ts := block.Transaction{
+		TxHash:   hash,
+		Vint:     []block.TXInput{{TxHash: parent, Index: 0}},
+		Vout:     []block.TXOutput{{Value: 1}},
+		LockTime: 0,
+	}
+	return &TxDesc{ts, fee, size, 0}
+}
+
+func TestBlockTemplate(t *testing.T) {
+	t.Log("测试按交易包的手续费率选取交易,父交易排在子交易之前")
+	{
+		mp := NewMempool(nil)
+		mp.insert(newTestDesc([]byte("a"), []byte("utxo1"), 10, 100))
+		mp.insert(newTestDesc([]byte("b"), []byte("utxo2"), 50, 100))
+		//c花费a的输出,手续费率最高,但必须排在a之后
+		mp.insert(newTestDesc([]byte("c"), []byte("a"), 90, 100))
+		tss := mp.BlockTemplate(0)
+		order := [][]byte{[]byte("b"), []byte("a"), []byte("c")}
+		if len(tss) != len(order) {
+			t.Fatalf("\t选取的交易数量不正确：%d", len(tss))
+		}
+		for i := range order {
+			if !bytes.Equal(tss[i].TxHash, order[i]) {
+				t.Fatalf("\t第%d笔交易应为%s,实际为%s", i+1, order[i], tss[i].TxHash)
+			}
+		}
+		//大小限制
+		if tss := mp.BlockTemplate(150); len(tss) != 1 || !bytes.Equal(tss[0].TxHash, []byte("b")) {
+			t.Fatalf("\t大小限制下选取的交易不正确")
+		}
+		t.Log("\t交易选取顺序正确")
+	}
+	t.Log("测试手续费率高的子交易带着手续费率低的父交易一起被选取(CPFP)")
+	{
+		mp := NewMempool(nil)
+		mp.insert(newTestDesc([]byte("a"), []byte("utxo1"), 10, 100))
+		mp.insert(newTestDesc([]byte("b"), []byte("utxo2"), 50, 100))
+		mp.insert(newTestDesc([]byte("c"), []byte("a"), 110, 100))
+		//a与c组成的交易包手续费率为120/200,高于b的50/100
+		tss := mp.BlockTemplate(200)
+		if len(tss) != 2 || !bytes.Equal(tss[0].TxHash, []byte("a")) || !bytes.Equal(tss[1].TxHash, []byte("c")) {
+			t.Fatalf("\t子交易没有带着父交易一起被选取")
+		}
+		//a被选取后,d的交易包只剩下d本身
+		mp.insert(newTestDesc([]byte("d"), []byte("a"), 40, 100))
+		order := [][]byte{[]byte("a"), []byte("c"), []byte("b"), []byte("d")}
+		tss = mp.BlockTemplate(0)
+		for i := range order {
+			if !bytes.Equal(tss[i].TxHash, order[i]) {
+				t.Fatalf("\t第%d笔交易应为%s,实际为%s", i+1, order[i], tss[i].TxHash)
+			}
+		}
+		t.Log("\t交易包选取正确")
+	}
+}
+
+func TestRemoveWithDescendants(t *testing.T) {
+	t.Log("测试移出交易时连同后代交易一并移出")
+	{
+		mp := NewMempool(nil)
+		mp.insert(newTestDesc([]byte("a"), []byte("utxo1"), 10, 100))
+		mp.insert(newTestDesc([]byte("b"), []byte("a"), 10, 100))
+		mp.insert(newTestDesc([]byte("c"), []byte("b"), 10, 100))
+		mp.insert(newTestDesc([]byte("d"), []byte("utxo2"), 10, 100))
+		mp.remove([]byte("a"), true)
+		if mp.Count() != 1 || !mp.Has([]byte("d")) || mp.Size() != 100 {
+			t.Fatalf("\t移出后交易池状态不正确：数量%d,大小%d", mp.Count(), mp.Size())
+		}
+		t.Log("\t后代交易已一并移出")
+	}
+}
+
+func TestOrphanPool(t *testing.T) {
+	t.Log("测试孤儿交易池按缺失的父交易建立索引,并在已满时驱逐孤儿交易")
+	{
+		max := MaxOrphanTxs
+		MaxOrphanTxs = 2
+		defer func() { MaxOrphanTxs = max }()
+		mp := NewMempool(nil)
+		for _, h := range []string{"a", "b", "c"} {
+			if err := mp.addOrphan(newTestDesc([]byte(h), []byte("parent"), 0, 0).Tx); err != ErrOrphanTransaction {
+				t.Fatalf("\t存入孤儿交易池返回的错误不正确：%v", err)
+			}
+		}
+		if len(mp.orphans) != 2 || len(mp.orphansByPrev["parent"]) != 2 {
+			t.Fatalf("\t孤儿交易池数量不正确：%d", len(mp.orphans))
+		}
+		for h := range mp.orphans {
+			mp.removeOrphan([]byte(h))
+		}
+		if len(mp.orphans) != 0 || len(mp.orphansByPrev) != 0 {
+			t.Fatalf("\t移出孤儿交易后索引未清理")
+		}
+		t.Log("\t孤儿交易池状态正确")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	t.Log("测试替换交易只能替换声明了可替换的冲突交易,并连同其后代交易一并替换")
+	{
+		mp := NewMempool(nil)
+		a := newTestDesc([]byte("a"), []byte("utxo1"), 10, 100)
+		a.Tx.Vint[0].Sequence = block.SequenceReplaceable
+		mp.insert(a)
+		mp.insert(newTestDesc([]byte("b"), []byte("a"), 10, 100))
+		mp.insert(newTestDesc([]byte("c"), []byte("utxo2"), 10, 100))
+		replacement := newTestDesc([]byte("a2"), []byte("utxo1"), 30, 100).Tx
+		replaced, err := mp.conflicts(&replacement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(replaced) != 2 || replaced["a"] == nil || replaced["b"] == nil {
+			t.Fatalf("\t需要替换的交易不正确:%d笔", len(replaced))
+		}
+		//c没有声明可替换
+		other := newTestDesc([]byte("c2"), []byte("utxo2"), 30, 100).Tx
+		if _, err := mp.conflicts(&other); err == nil {
+			t.Fatalf("\t替换了没有声明可替换的交易")
+		}
+		free := newTestDesc([]byte("d"), []byte("utxo3"), 30, 100).Tx
+		if replaced, err := mp.conflicts(&free); err != nil || len(replaced) != 0 {
+			t.Fatalf("\t没有冲突的交易返回了需要替换的交易")
+		}
+		t.Log("\t冲突交易判断正确")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+	孤儿交易池:引用的输出在utxo数据库与交易池中都找不到的交易,
+	按缺失的父交易hash索引暂存,父交易进入交易池或被打包后再重新校验;孤儿交易有数量上限且会超时失效
+*/
+package mempool
+
+import (
+	"errors"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	log "github.com/corgi-kx/logcustom"
+	"time"
+)
+
+//孤儿交易池中最多存放的交易数量
+var MaxOrphanTxs = 100
+
+//孤儿交易在孤儿交易池中最多停留的时间(秒)
+var OrphanTxExpiry int64 = 20 * 60
+
+//单笔孤儿交易的大小上限(字节),避免大量无效数据占用内存
+const maxOrphanTxSize = 100000
+
+//交易已存入孤儿交易池
+var ErrOrphanTransaction = errors.New("Add err : 交易引用的输出尚不存在,已暂存入孤儿交易池")
+
+type orphanTx struct {
+	tx block.Transaction
+	//失效时间
+	expire int64
+}
+
+//判断交易校验失败的原因是否为引用的输出不存在
+func isMissingInputs(err error) bool {
+	verr, ok := err.(*block.ValidationError)
+	return ok && verr.Reason == block.RejectMissingInputs
+}
+
+//将交易存入孤儿交易池,孤儿交易池已满时丢弃最早失效的孤儿交易
+func (mp *Mempool) addOrphan(ts block.Transaction) error {
+	hash := string(ts.TxHash)
+	if _, ok := mp.orphans[hash]; ok {
+		return ErrOrphanTransaction
+	}
+	if ts.Size() > maxOrphanTxSize {
+		return errors.New("Add err : 孤儿交易过大,不予暂存")
+	}
+	mp.expireOrphans()
+	if len(mp.orphans) >= MaxOrphanTxs {
+		var oldest []byte
+		var oldestExpire int64
+		for _, o := range mp.orphans {
+			if oldest == nil || o.expire < oldestExpire {
+				oldest, oldestExpire = o.tx.TxHash, o.expire
+			}
+		}
+		mp.removeOrphan(oldest)
+	}
+	mp.orphans[hash] = &orphanTx{ts, time.Now().Unix() + OrphanTxExpiry}
+	//按交易池中没有的父交易建立索引
+	for _, vIn := range ts.Vint {
+		if _, ok := mp.pool[string(vIn.TxHash)]; ok {
+			continue
+		}
+		prev := string(vIn.TxHash)
+		if mp.orphansByPrev[prev] == nil {
+			mp.orphansByPrev[prev] = map[string]bool{}
+		}
+		mp.orphansByPrev[prev][hash] = true
+	}
+	log.Debugf("交易%x引用的输出尚不存在,暂存入孤儿交易池", ts.TxHash)
+	return ErrOrphanTransaction
+}
+
+func (mp *Mempool) removeOrphan(hash []byte) {
+	o, ok := mp.orphans[string(hash)]
+	if !ok {
+		return
+	}
+	for _, vIn := range o.tx.Vint {
+		prev := string(vIn.TxHash)
+		delete(mp.orphansByPrev[prev], string(hash))
+		if len(mp.orphansByPrev[prev]) == 0 {
+			delete(mp.orphansByPrev, prev)
+		}
+	}
+	delete(mp.orphans, string(hash))
+}
+
+//父交易进入交易池或被打包后,重新校验等待它的孤儿交易,通过的孤儿交易又会继续唤醒它自己的子交易
+func (mp *Mempool) processOrphans(hash []byte) {
+	queue := []string{string(hash)}
+	for len(queue) > 0 {
+		prev := queue[0]
+		queue = queue[1:]
+		//重新存入的孤儿交易可能再次加入同一个集合,先取出全部hash再处理,保证每个孤儿交易本轮只处理一次
+		orphanHashes := []string{}
+		for orphanHash := range mp.orphansByPrev[prev] {
+			orphanHashes = append(orphanHashes, orphanHash)
+		}
+		for _, orphanHash := range orphanHashes {
+			o, ok := mp.orphans[orphanHash]
+			if !ok {
+				continue
+			}
+			mp.removeOrphan(o.tx.TxHash)
+			err := mp.add(o.tx, time.Now().Unix())
+			if isMissingInputs(err) {
+				//还缺少其他父交易,重新存入孤儿交易池
+				mp.addOrphan(o.tx)
+				continue
+			}
+			if err != nil {
+				log.Debugf("孤儿交易%x没有通过校验:%s", o.tx.TxHash, err)
+				continue
+			}
+			log.Debugf("孤儿交易%x的父交易已到达,已加入交易池", o.tx.TxHash)
+			queue = append(queue, orphanHash)
+		}
+	}
+}
+
+//丢弃已失效的孤儿交易
+func (mp *Mempool) expireOrphans() {
+	now := time.Now().Unix()
+	for hash, o := range mp.orphans {
+		if o.expire < now {
+			log.Debugf("孤儿交易%x已超时,从孤儿交易池中丢弃", o.tx.TxHash)
+			mp.removeOrphan([]byte(hash))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+	交易池持久化:交易进入或移出交易池时同步写入数据库,
+	节点重启后重新校验并载入,未打包的交易会定期重新广播,直到被打包或超时
+*/
+package mempool
+
+import (
+	"bytes"
+	"encoding/gob"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/database"
+	log "github.com/corgi-kx/logcustom"
+	"sort"
+	"time"
+)
+
+func (d *TxDesc) serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	err := encoder.Encode(d)
+	if err != nil {
+		panic(err)
+	}
+	return result.Bytes()
+}
+
+func (d *TxDesc) deserialize(b []byte) {
+	decoder := gob.NewDecoder(bytes.NewReader(b))
+	err := decoder.Decode(d)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+//从数据库中载入上次退出时交易池中的交易,并根据当前utxo数据库重新校验
+func (mp *Mempool) Load() {
+	if mp.bd == nil {
+		return
+	}
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	descs := []*TxDesc{}
+	mp.bd.ForEach(database.MempoolBucket, func(k, v []byte) {
+		desc := &TxDesc{}
+		desc.deserialize(v)
+		descs = append(descs, desc)
+	})
+	if len(descs) == 0 {
+		return
+	}
+	//先清空仓库,校验通过的交易在加入交易池时会重新写入
+	mp.bd.DeleteBucket(database.MempoolBucket)
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].Added < descs[j].Added
+	})
+	//子交易可能排在父交易之前,循环载入直到没有新的交易通过校验
+	now := time.Now().Unix()
+	for progress := true; progress; {
+		progress = false
+		rest := []*TxDesc{}
+		for _, d := range descs {
+			if now-d.Added > Expiry {
+				continue
+			}
+			if mp.add(d.Tx, d.Added) != nil {
+				rest = append(rest, d)
+				continue
+			}
+			progress = true
+		}
+		descs = rest
+	}
+	log.Infof("已从数据库载入%d笔未打包的交易,%d笔交易已失效", len(mp.pool), len(descs))
+}
+
+//移出超时仍未打包的交易,并丢弃超时的孤儿交易
+func (mp *Mempool) Expire() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.expireOrphans()
+	now := time.Now().Unix()
+	for _, d := range mp.pool {
+		if now-d.Added > Expiry {
+			log.Debugf("交易%x超时未被打包,移出交易池", d.Tx.TxHash)
+			mp.remove(d.Tx.TxHash, true)
+		}
+	}
+}
+
+//获取交易池中的全部交易,父交易排在子交易之前
+func (mp *Mempool) Transactions() []block.Transaction {
+	return mp.BlockTemplate(0)
+}
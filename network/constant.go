@@ -1,27 +1,42 @@
 package network
 
-import "github.com/libp2p/go-libp2p-core/host"
+import (
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/mempool"
+	"github.com/libp2p/go-libp2p-core/host"
+)
 
-//p2p相关,程序启动时,会被配置文件所替换
+//p2p相关,程序启动时,会被配置文件所替换(节点组名称与协议id由网络参数决定)
 var (
-	RendezvousString = "meetme"
-	ProtocolID       = "/chain/1.1.0"
-	ListenHost       = "0.0.0.0"
-	ListenPort       = "3001"
-	localHost        host.Host
-	localAddr        string
+	ListenHost = "0.0.0.0"
+	ListenPort = "3001"
+	localHost  host.Host
+	localAddr  string
 )
 
 //交易池
-var tradePool = Transactions{}
+var txPool = mempool.NewMempool(database.New())
+
+//出块间隔(秒):距离最新区块超过该时间后,打包交易池中的交易出块(没有交易时出空块)
+var BlockInterval int64 = 30
 
-//交易池默认大小
-var TradePoolLength = 2
+//重新广播交易池中交易的间隔(秒)
+var RebroadcastInterval = 60
 
 //版本信息 默认0
 const versionInfo = byte(0x00)
 
-//发送数据的头部多少位为命令
+//接收一条网络消息的大小上限(字节):区块大小上限加上gob编码的额外开销
+const maxMessageSize = 2 * block.MaxBlockSize
+
+//一次最多发送的区块头数量
+const maxHeadersPerMsg = 500
+
+//发送数据的头部多少位为网络魔数
+const prefixMagicLength = 4
+
+//网络魔数之后多少位为命令
 const prefixCMDLength = 12
 
 type command string
@@ -29,8 +44,8 @@ type command string
 //网络通讯互相发送的命令
 const (
 	cVersion     command = "version"
-	cGetHash     command = "getHash"
-	cHashMap     command = "hashMap"
+	cGetHeaders  command = "getHeaders"
+	cHeaders     command = "headers"
 	cGetBlock    command = "getBlock"
 	cBlock       command = "block"
 	cTransaction command = "transaction"
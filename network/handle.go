@@ -1,32 +1,46 @@
 package network
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	blc "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/mempool"
 	log "github.com/corgi-kx/logcustom"
 	"github.com/libp2p/go-libp2p-core/network"
+	"io"
 	"io/ioutil"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //对接收到的数据解析出命令,然后对不同的命令分别进行处理
 func handleStream(stream network.Stream) {
-	data, err := ioutil.ReadAll(stream)
+	//限制读取的数据大小,超过上限的数据不进行解析
+	data, err := ioutil.ReadAll(io.LimitReader(stream, maxMessageSize+1))
 	if err != nil {
 		log.Panic(err)
 	}
-	//取信息的前十二位得到命令
+	if len(data) > maxMessageSize {
+		log.Warnf("接收到的数据超过%d字节,已丢弃", maxMessageSize)
+		stream.Reset()
+		return
+	}
+	//校验网络魔数,并取之后的十二位得到命令
 	cmd, content := splitMessage(data)
+	if cmd == "" {
+		log.Warn("接收到的数据不属于当前网络,已丢弃")
+		stream.Reset()
+		return
+	}
 	log.Tracef("本节点已接收到命令：%s", cmd)
 	switch command(cmd) {
 	case cVersion:
 		go handleVersion(content)
-	case cGetHash:
-		go handleGetHash(content)
-	case cHashMap:
-		go handleHashMap(content)
+	case cGetHeaders:
+		go handleGetHeaders(content)
+	case cHeaders:
+		go handleHeaders(content)
 	case cGetBlock:
 		go handleGetBlock(content)
 	case cBlock:
@@ -44,130 +58,124 @@ func handleMyError(content []byte) {
 	e.deserialize(content)
 	log.Warn(e.Error)
 	peer := buildPeerInfoByAddr(e.Addrfrom)
-	delete(peerPool, fmt.Sprint(peer.ID))
+	removePeer(fmt.Sprint(peer.ID))
 }
 
-//接收交易信息，满足条件后进行挖矿
+//接收交易信息,校验通过的交易放入交易池,由出块循环打包
 func handleTransaction(content []byte) {
 	t := Transactions{}
 	t.Deserialize(content)
-	if len(t.Ts) == 0 {
-		log.Error("没有满足条件的转账信息，顾不存入交易池")
-		return
-	}
-	//交易池中只能存在每个地址的一笔交易信息
-	//判断当前交易池中是否已有该地址发起的交易
-	if len(tradePool.Ts) != 0 {
-	circle:
-		for i := range t.Ts {
-			for _, v := range tradePool.Ts {
-				if bytes.Equal(t.Ts[i].Vint[0].PublicKey, v.Vint[0].PublicKey) {
-					s := fmt.Sprintf("当前交易池里，已存在此笔地址转账信息(%s)，顾暂不能进行转账，请等待上笔交易出块后在进行此地址转账操作", blc.GetAddressFromPublicKey(t.Ts[i].Vint[0].PublicKey))
-					log.Error(s)
-					t.Ts = append(t.Ts[:i], t.Ts[i+1:]...)
-					goto circle
-				}
-			}
+	accepted := 0
+	for i := range t.Ts {
+		//重新广播的交易可能已存在于交易池中
+		if txPool.Has(t.Ts[i].TxHash) {
+			continue
 		}
+		err := txPool.Add(t.Ts[i].toBlc())
+		if err == mempool.ErrOrphanTransaction {
+			log.Debugf("交易%x的父交易尚未到达,已暂存入孤儿交易池", t.Ts[i].TxHash)
+			continue
+		}
+		if err != nil {
+			log.Warnf("交易%x未能放入交易池:%s", t.Ts[i].TxHash, err)
+			continue
+		}
+		accepted++
 	}
-
-	if len(t.Ts) == 0 {
+	if accepted == 0 {
+		log.Debug("没有新的满足条件的转账信息存入交易池")
 		return
 	}
-	mineBlock(t)
+	log.Debugf("%d笔交易已存入交易池,当前交易池数量:%d,大小:%d字节", accepted, txPool.Count(), txPool.Size())
 }
 
 //调用区块模块进行挖矿操作
 var lock = sync.Mutex{}
 
-func mineBlock(t Transactions) {
-	//锁上,等待上一个挖矿结束后才进行挖矿!
-	lock.Lock()
-	defer lock.Unlock()
-	//将临时交易池的交易添加进交易池
-	tradePool.Ts = append(tradePool.Ts, t.Ts...)
+//是否开启本节点挖矿,可以在运行时通过命令行开关
+var miningEnabled int32 = 1
+
+//开启或关闭本节点挖矿,关闭时终止正在进行的挖矿
+func SetMining(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&miningEnabled, 1)
+		return
+	}
+	atomic.StoreInt32(&miningEnabled, 0)
+	blc.CancelMining()
+}
 
+//本节点是否开启了挖矿
+func IsMining() bool {
+	return atomic.LoadInt32(&miningEnabled) == 1
+}
+
+//出块循环:距离最新区块的时间达到出块间隔,或交易池中的交易已足够填满一个区块时,打包交易池中的交易进行挖矿
+//交易池为空时出空块,保证没有交易时区块链也能继续增长
+func produceBlocks() {
 	for {
-		//满足交易池规定的大小后进行挖矿
-		if len(tradePool.Ts) >= TradePoolLength {
-			log.Debugf("交易池已满足挖矿交易数量大小限制:%d,即将进行挖矿", TradePoolLength)
-			mineTrans := Transactions{make([]Transaction, TradePoolLength)}
-			copy(mineTrans.Ts, tradePool.Ts[:TradePoolLength])
-
-			bc := blc.NewBlockchain()
-			//如果当前节点区块高度小于网络最新高度，则等待节点更新区块后在进行挖矿
-			for {
-				currentHeight := bc.GetLastBlockHeight()
-				if currentHeight >= blc.NewestBlockHeight {
-					break
-				}
-				time.Sleep(time.Second * 1)
-			}
-			//将network下的transaction转换为blc下的transaction
-			nTs := make([]blc.Transaction, len(mineTrans.Ts))
-			for i := range mineTrans.Ts {
-				nTs[i].TxHash = mineTrans.Ts[i].TxHash
-				nTs[i].Vint = mineTrans.Ts[i].Vint
-				nTs[i].Vout = mineTrans.Ts[i].Vout
-			}
-			//进行转帐挖矿
-			bc.Transfer(nTs, send)
-			//剔除已打包进区块的交易
-			newTrans := []Transaction{}
-			newTrans = append(newTrans, tradePool.Ts[TradePoolLength:]...)
-			tradePool.Ts = newTrans
-		} else {
-			log.Infof("当前交易池数量:%d，交易池未满%d，暂不进行挖矿操作", len(tradePool.Ts), TradePoolLength)
-			break
+		time.Sleep(time.Second)
+		if !IsMining() {
+			continue
+		}
+		bc := blc.NewBlockchain()
+		height := bc.GetLastBlockHeight()
+		//还没有创世区块,或本节点区块高度小于网络最新高度时,等待节点更新区块后再进行挖矿
+		if height == 0 || height < blc.NewestBlockHeight {
+			continue
+		}
+		if time.Now().Unix()-bc.GetLastBlockTime() < BlockInterval && txPool.Size() < mempool.BlockMaxSize {
+			continue
+		}
+		mineBlock()
+		//没有成功出块(例如poa共识下没有轮到本节点,或挖矿被网络中的新区块打断)时,等待一个出块间隔后再尝试
+		if bc.GetLastBlockHeight() == height {
+			time.Sleep(time.Second * time.Duration(BlockInterval))
 		}
 	}
 }
 
+//按交易包的手续费率从交易池中选取不超过区块大小上限的交易进行转帐挖矿,出块后已打包的交易会被移出交易池
+func mineBlock() {
+	//锁上,等待上一个挖矿结束后才进行挖矿!
+	lock.Lock()
+	defer lock.Unlock()
+	log.Debugf("即将进行挖矿,当前交易池数量:%d,大小:%d字节", txPool.Count(), txPool.Size())
+	bc := blc.NewBlockchain()
+	bc.Transfer(txPool.BlockTemplate(mempool.BlockMaxSize), send)
+}
+
 //接收到区块数据,进行验证后加入数据库
 func handleBlock(content []byte) {
 	block := &blc.Block{}
 	block.Deserialize(content)
 	log.Infof("本节点已接收到来自其他节点的区块数据，该块hash为：%x", block.Hash)
+	isOrphan, err := processBlock(block)
+	if err == errGenesisExists {
+		return
+	}
+	if err != nil {
+		log.Errorf("区块%x没有通过校验,无法加入数据库:%s", block.Hash, err)
+		return
+	}
+	if isOrphan {
+		return
+	}
+	log.Infof("总验证通过已存入本地库,区块高度%d,哈希%x", block.Height, block.Hash)
+}
+
+//如果已有创世区块,则不再接收其他创世区块
+var errGenesisExists = errors.New("本地已存在创世区块")
+
+//校验区块并存入本地库,网络中接收到的区块与外部矿工提交的区块都经过这里
+//进行完整的共识校验后存入本地库,由区块链根据累计工作量决定是否切换主链
+//如果找不到上一个区块,可能是还未同步,先存入孤块池,等上一个区块到达后再处理
+func processBlock(block *blc.Block) (bool, error) {
 	bc := blc.NewBlockchain()
-	pow := blc.NewProofOfWork(block)
-	//重新计算本块hash,进行pow验证
-	if pow.Verify() {
-		log.Infof("POW验证通过,该区块高度为：%d", block.Height)
-		//如果是创世区块则直接添加进本地库
-		currentHash := bc.GetBlockHashByHeight(block.Height)
-		if block.Height == 1 && currentHash == nil {
-			bc.AddBlock(block)
-			utxos := blc.UTXOHandle{bc}
-			utxos.ResetUTXODataBase() //重置utxo数据库
-			log.Info("创世区块验证通过,已存入本地数据库...")
-		}
-		//验证上一个区块的hash与本块中prehash是否一致
-		lastBlockHash := bc.GetBlockHashByHeight(block.Height - 1)
-		if lastBlockHash == nil {
-			//如果找不到上一个区块,可能是还未同步,建立个循环等待同步
-			for {
-				time.Sleep(time.Second)
-				lastBlockHash = bc.GetBlockHashByHeight(block.Height - 1)
-				if lastBlockHash != nil {
-					log.Debugf("区块高度%d尚未同步,等待同步...", block.Height-1)
-					break
-				}
-			}
-		}
-		//如果上一块的hash等于本块prehash则通过存入本地库
-		if bytes.Equal(lastBlockHash, block.PreHash) {
-			bc.AddBlock(block)
-			utxos := blc.UTXOHandle{bc}
-			//重置utxo数据库
-			utxos.ResetUTXODataBase()
-			log.Infof("prehash验证通过,该区块高度为:%d,", block.Height)
-			log.Infof("总验证通过已存入本地库,区块高度%d,哈希%x", block.Height, block.Hash)
-		} else {
-			log.Infof("上一个块高度为%d的hash值为:%x,与本块中的prehash值:%x不一致,固不存入区块链中", block.Height-1, lastBlockHash, block.Hash)
-		}
-	} else {
-		log.Errorf("POW验证不通过，无法将此块：%x加入数据库", block.Hash)
+	if block.Height == 1 && bc.GetBlockHashByHeight(1) != nil {
+		return false, errGenesisExists
 	}
+	return bc.ProcessBlock(block)
 }
 
 //接收到获取区块命令,通过hash值 找到该区块 然后把该区块发送过去
@@ -181,41 +189,48 @@ func handleGetBlock(content []byte) {
 	send.SendMessage(buildPeerInfoByAddr(g.AddrFrom), data)
 }
 
-//从对面节点处获取到本地区块链所没有的区块hash列表,然后依次发送"获取区块命令"到该节点
-func handleHashMap(content []byte) {
-	h := hash{}
+//接收到对方发来的区块头,先校验整条区块头链,通过后再依次向对方获取本地所没有的区块
+func handleHeaders(content []byte) {
+	h := headers{}
 	h.deserialize(content)
-	hm := h.HashMap
+	if len(h.Headers) == 0 {
+		return
+	}
 	bc := blc.NewBlockchain()
-	lastHeight := bc.GetLastBlockHeight()
-	targetHeight := lastHeight + 1
-	for {
-		hash := hm[targetHeight]
-		if hash == nil {
-			break
+	hashes, err := bc.AddHeaders(h.Headers)
+	if err != nil {
+		log.Error(err)
+	}
+	log.Debugf("已校验%d个区块头,准备获取区块数据", len(hashes))
+	for _, hash := range hashes {
+		//本地已存在的区块无需再次获取
+		if len(bc.GetBlockByHash(hash)) != 0 {
+			continue
 		}
 		g := getBlock{hash, localAddr}
 		data := jointMessage(cGetBlock, g.serialize())
 		send.SendMessage(buildPeerInfoByAddr(h.AddrFrom), data)
-		log.Debugf("已发送获取区块信息命令,目标高度为：%d", targetHeight)
-		targetHeight++
+		log.Debugf("已发送获取区块信息命令,目标hash为：%x", hash)
+	}
+	//对方一次只会返回有限个区块头,如果收满了说明还有后续的区块头,继续获取
+	if err == nil && len(h.Headers) == maxHeadersPerMsg {
+		locator := append([][]byte{hashes[len(hashes)-1]}, bc.GetBlockLocator()...)
+		gh := getHeaders{locator, localAddr}
+		data := jointMessage(cGetHeaders, gh.serialize())
+		send.SendMessage(buildPeerInfoByAddr(h.AddrFrom), data)
 	}
 }
 
-//接收到"获取hash列表"命令,返回对面节点所没有的区块的hash信息(两条链的高度差)
-func handleGetHash(content []byte) {
-	g := getHash{}
+//接收到"获取区块头"命令,返回对方所没有的主链区块头(从分叉点之后开始)
+func handleGetHeaders(content []byte) {
+	g := getHeaders{}
 	g.deserialize(content)
 	bc := blc.NewBlockchain()
-	lastHeight := bc.GetLastBlockHeight()
-	hm := hashMap{}
-	for i := g.Height + 1; i <= lastHeight; i++ {
-		hm[i] = bc.GetBlockHashByHeight(i)
-	}
-	h := hash{hm, localAddr}
-	data := jointMessage(cHashMap, h.serialize())
+	hs := bc.GetHeadersByLocator(g.Locator, maxHeadersPerMsg)
+	h := headers{hs, localAddr}
+	data := jointMessage(cHeaders, h.serialize())
 	send.SendMessage(buildPeerInfoByAddr(g.AddrFrom), data)
-	log.Debug("已发送获取hash列表命令")
+	log.Debugf("已发送%d个区块头", len(hs))
 }
 
 //接收到其他节点的区块高度信息,与本地区块高度进行对比
@@ -225,6 +240,11 @@ func handleVersion(content []byte) {
 	defer lock.Unlock()
 	v := version{}
 	v.deserialize(content)
+	if v.ChainID != blc.ActiveParams.ChainID {
+		log.Warnf("节点%s的链id为%d,与本节点的链id%d不同,已拒绝该节点", v.AddrFrom, v.ChainID, blc.ActiveParams.ChainID)
+		refusePeer(v.AddrFrom)
+		return
+	}
 	bc := blc.NewBlockchain()
 	if blc.NewestBlockHeight > v.Height {
 		log.Info("目标高度比本链小，准备向目标发送版本信息")
@@ -234,17 +254,19 @@ func handleVersion(content []byte) {
 				log.Info("当前正在更新区块信息,稍后将发送版本信息...")
 				time.Sleep(time.Second)
 			} else {
-				newV := version{versionInfo, currentHeight, localAddr}
+				newV := version{versionInfo, blc.ActiveParams.ChainID, currentHeight, localAddr}
 				data := jointMessage(cVersion, newV.serialize())
 				send.SendMessage(buildPeerInfoByAddr(v.AddrFrom), data)
 				break
 			}
 		}
 	} else if blc.NewestBlockHeight < v.Height {
-		log.Debugf("对方版本比咱们大%v,发送获取区块的hash信息！", v)
-		gh := getHash{blc.NewestBlockHeight, localAddr}
+		log.Debugf("对方版本比咱们大%v,发送获取区块头的信息！", v)
+		gh := getHeaders{bc.GetBlockLocator(), localAddr}
 		blc.NewestBlockHeight = v.Height
-		data := jointMessage(cGetHash, gh.serialize())
+		//网络中已有更高的区块,停止挖当前高度的区块,先同步
+		blc.CancelMining()
+		data := jointMessage(cGetHeaders, gh.serialize())
 		send.SendMessage(buildPeerInfoByAddr(v.AddrFrom), data)
 	} else {
 		log.Debug("接收到版本信息，双方高度一致，无需处理！")
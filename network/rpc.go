@@ -0,0 +1,242 @@
+/*
+	外部矿工接口:以json-rpc的形式提供区块模板(getblocktemplate),并接收外部矿工提交的区块(submitblock)
+	或只替换了nonce与时间戳的区块头(submitheader),提交的区块与网络中接收到的区块经过同样的校验
+*/
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	blc "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/mempool"
+	log "github.com/corgi-kx/logcustom"
+	"net/http"
+	"sync"
+)
+
+//json-rpc监听地址,为空时不启动
+var RPCListen = ""
+
+//最多保留的区块模板数量,外部矿工提交区块头时通过workid找到对应的模板
+const maxWorkTemplates = 16
+
+type rpcRequest struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	ID     interface{} `json:"id"`
+	Result interface{} `json:"result"`
+	Error  *rpcError   `json:"error"`
+}
+
+//区块模板中的交易
+type templateTransaction struct {
+	//交易hash
+	Hash string `json:"hash"`
+	//gob序列化后的交易数据
+	Data string `json:"data"`
+	//交易大小(字节)
+	Size int `json:"size"`
+}
+
+type blockTemplateResult struct {
+	Version           int32  `json:"version"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	Height            int    `json:"height"`
+	Bits              string `json:"bits"`
+	Target            string `json:"target"`
+	CurTime           int64  `json:"curtime"`
+	MerkleRoot        string `json:"merkleroot"`
+	//提交区块头时用来找到本模板
+	WorkID string `json:"workid"`
+	//奖励交易,没有设置奖励地址时奖励作废(只有一个金额为0的OP_RETURN输出)
+	Coinbase      *templateTransaction  `json:"coinbasetxn"`
+	CoinbaseValue int                   `json:"coinbasevalue"`
+	Transactions  []templateTransaction `json:"transactions"`
+	//nonce为0时的区块头拼接数据,sha256(header)即为区块hash
+	Header string `json:"header"`
+	//nonce(8字节大端)在header中的偏移量
+	NonceOffset int `json:"nonceoffset"`
+}
+
+//已发给外部矿工的区块模板 key:workid
+var workLock = sync.Mutex{}
+var workTemplates = map[string]*blc.Block{}
+var workOrder = []string{}
+
+//启动json-rpc服务
+func startRPCServer() {
+	if RPCListen == "" {
+		return
+	}
+	log.Infof("[*] json-rpc监听地址: %s", RPCListen)
+	err := http.ListenAndServe(RPCListen, http.HandlerFunc(handleRPC))
+	if err != nil {
+		log.Errorf("json-rpc服务启动失败:%s", err)
+	}
+}
+
+func handleRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+	req := rpcRequest{}
+	resp := rpcResponse{}
+	//提交的区块以十六进制编码,请求大小不会超过网络消息大小上限的两倍
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxMessageSize)).Decode(&req)
+	if err != nil {
+		resp.Error = &rpcError{-32700, "请求格式错误:" + err.Error()}
+	} else {
+		resp.ID = req.ID
+		resp.Result, err = callRPC(req.Method, req.Params)
+		if err != nil {
+			resp.Error = &rpcError{-1, err.Error()}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func callRPC(method string, params []json.RawMessage) (interface{}, error) {
+	switch method {
+	case "getblocktemplate":
+		address := ""
+		if len(params) > 0 {
+			if err := json.Unmarshal(params[0], &address); err != nil {
+				return nil, errors.New("奖励地址参数格式错误")
+			}
+		}
+		return getBlockTemplate(address)
+	case "submitblock":
+		var data string
+		if len(params) < 1 || json.Unmarshal(params[0], &data) != nil {
+			return nil, errors.New("参数应为十六进制编码的区块数据")
+		}
+		return nil, submitBlock(data)
+	case "submitheader":
+		var workID string
+		var nonce, timeStamp int64
+		if len(params) < 2 || json.Unmarshal(params[0], &workID) != nil || json.Unmarshal(params[1], &nonce) != nil {
+			return nil, errors.New("参数应为workid与nonce,以及可选的时间戳")
+		}
+		if len(params) > 2 && json.Unmarshal(params[2], &timeStamp) != nil {
+			return nil, errors.New("时间戳参数格式错误")
+		}
+		return nil, submitHeader(workID, nonce, timeStamp)
+	default:
+		return nil, fmt.Errorf("不支持的方法:%s", method)
+	}
+}
+
+//从交易池选取交易生成区块模板,address为空时使用本节点设置的挖矿奖励地址
+func getBlockTemplate(address string) (*blockTemplateResult, error) {
+	if blc.Engine.Name() != "pow" {
+		return nil, errors.New("当前共识不是工作量证明,不提供区块模板")
+	}
+	bc := blc.NewBlockchain()
+	if address == "" {
+		address = bc.GetRewardAddress()
+	}
+	b, err := bc.NewBlockTemplate(txPool.BlockTemplate(mempool.BlockMaxSize), address)
+	if err != nil {
+		return nil, err
+	}
+	header, offset := b.WorkData()
+	result := &blockTemplateResult{
+		Version:           b.Version,
+		PreviousBlockHash: hex.EncodeToString(b.PreHash),
+		Height:            b.Height,
+		Bits:              fmt.Sprintf("%08x", b.Bits),
+		Target:            fmt.Sprintf("%064x", blc.CompactToBig(b.Bits)),
+		CurTime:           b.TimeStamp,
+		MerkleRoot:        hex.EncodeToString(b.MerkleRoot),
+		WorkID:            hex.EncodeToString(b.MerkleRoot),
+		Transactions:      []templateTransaction{},
+		Header:            hex.EncodeToString(header),
+		NonceOffset:       offset,
+	}
+	for i := range b.Transactions {
+		ts := &b.Transactions[i]
+		t := templateTransaction{hex.EncodeToString(ts.TxHash), hex.EncodeToString(ts.Serialize()), ts.Size()}
+		if ts.IsCoinbase() {
+			result.Coinbase = &t
+			result.CoinbaseValue = ts.Vout[0].Value
+			continue
+		}
+		result.Transactions = append(result.Transactions, t)
+	}
+	saveWorkTemplate(result.WorkID, b)
+	return result, nil
+}
+
+//保存区块模板,超过数量上限时丢弃最早的模板
+func saveWorkTemplate(workID string, b *blc.Block) {
+	workLock.Lock()
+	defer workLock.Unlock()
+	if _, ok := workTemplates[workID]; !ok {
+		workOrder = append(workOrder, workID)
+	}
+	workTemplates[workID] = b
+	for len(workOrder) > maxWorkTemplates {
+		delete(workTemplates, workOrder[0])
+		workOrder = workOrder[1:]
+	}
+}
+
+//接收外部矿工提交的完整区块(gob序列化后的十六进制数据)
+func submitBlock(data string) error {
+	blockBytes, err := hex.DecodeString(data)
+	if err != nil {
+		return errors.New("区块数据不是十六进制编码")
+	}
+	b := &blc.Block{}
+	err = gob.NewDecoder(bytes.NewReader(blockBytes)).Decode(b)
+	if err != nil {
+		return fmt.Errorf("区块数据解析失败:%s", err)
+	}
+	return acceptMinedBlock(b)
+}
+
+//接收外部矿工提交的区块头:在对应的区块模板上替换nonce与时间戳(为0时不替换)
+func submitHeader(workID string, nonce, timeStamp int64) error {
+	workLock.Lock()
+	template, ok := workTemplates[workID]
+	workLock.Unlock()
+	if !ok {
+		return errors.New("找不到workid对应的区块模板,可能已过期")
+	}
+	b := *template
+	b.Nonce = nonce
+	if timeStamp != 0 {
+		b.TimeStamp = timeStamp
+	}
+	b.Hash = b.CalcHash()
+	return acceptMinedBlock(&b)
+}
+
+//外部矿工提交的区块与网络中接收到的区块经过同样的校验,加入主链后通知其他节点
+func acceptMinedBlock(b *blc.Block) error {
+	log.Infof("接收到外部矿工提交的区块,高度%d,hash为%x", b.Height, b.Hash)
+	isOrphan, err := processBlock(b)
+	if err != nil {
+		return err
+	}
+	if isOrphan {
+		return errors.New("找不到区块的上一个区块,已暂存入孤块池")
+	}
+	send.SendVersionToPeers(b.Height)
+	return nil
+}
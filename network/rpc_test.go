@@ -0,0 +1,216 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	block "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/database"
+	"github.com/corgi-kx/blockchain_golang/mempool"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+//json-rpc应答,结果留到具体测试中再解析
+type testRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+//在临时目录中创建一条只有创世区块的回归测试网区块链,交易池与区块模板也使用新的,测试结束后恢复原来的设置
+func newTestRPCChain(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, params, pool := block.ListenPort, block.ActiveParams, txPool
+	block.ListenPort, database.ListenPort = "rpctest", "rpctest"
+	err = block.SetChainParams("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txPool = mempool.NewMempool(database.New())
+	workTemplates, workOrder = map[string]*block.Block{}, []string{}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		block.ListenPort, database.ListenPort = port, port
+		block.ActiveParams, txPool = params, pool
+		workTemplates, workOrder = map[string]*block.Block{}, []string{}
+	})
+	err = block.NewBlockchain().InitGenesisBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+//通过json-rpc接口调用method
+func callTestRPC(t *testing.T, method string, params ...interface{}) testRPCResponse {
+	body, err := json.Marshal(map[string]interface{}{"id": 1, "method": method, "params": params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postTestRPC(t, string(body))
+}
+
+//向json-rpc接口发送原始请求
+func postTestRPC(t *testing.T, body string) testRPCResponse {
+	w := httptest.NewRecorder()
+	handleRPC(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	resp := testRPCResponse{}
+	err := json.NewDecoder(w.Body).Decode(&resp)
+	if err != nil {
+		t.Fatalf("\t应答不是json格式:%s", err)
+	}
+	return resp
+}
+
+//获取区块模板
+func getTestTemplate(t *testing.T) *blockTemplateResult {
+	resp := callTestRPC(t, "getblocktemplate")
+	if resp.Error != nil {
+		t.Fatalf("\t获取区块模板失败:%s", resp.Error.Message)
+	}
+	tpl := &blockTemplateResult{}
+	err := json.Unmarshal(resp.Result, tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
+
+//像外部矿工一样只根据模板中的区块头数据求解nonce,返回满足目标值的nonce以及一个不满足的nonce
+func solveTestTemplate(t *testing.T, tpl *blockTemplateResult) (good, bad int64) {
+	header, err := hex.DecodeString(tpl.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := new(big.Int).SetString(tpl.Target, 16)
+	good, bad = -1, -1
+	for nonce := int64(0); good < 0 || bad < 0; nonce++ {
+		binary.BigEndian.PutUint64(header[tpl.NonceOffset:], uint64(nonce))
+		hash := sha256.Sum256(header)
+		if new(big.Int).SetBytes(hash[:]).Cmp(target) == -1 {
+			if good < 0 {
+				good = nonce
+			}
+		} else if bad < 0 {
+			bad = nonce
+		}
+	}
+	return good, bad
+}
+
+func TestRPCSubmitHeader(t *testing.T) {
+	t.Log("测试外部矿工获取区块模板,求解后通过submitheader提交")
+	{
+		newTestRPCChain(t)
+		bc := block.NewBlockchain()
+		genesisHash := bc.GetBlockHashByHeight(1)
+		tpl := getTestTemplate(t)
+		if tpl.Height != 2 || tpl.PreviousBlockHash != hex.EncodeToString(genesisHash) || tpl.Coinbase == nil {
+			t.Fatalf("\t区块模板内容不正确:高度%d,上一个区块%s", tpl.Height, tpl.PreviousBlockHash)
+		}
+		good, bad := solveTestTemplate(t, tpl)
+		resp := callTestRPC(t, "submitheader", tpl.WorkID, bad)
+		if resp.Error == nil || bc.GetLastBlockHeight() != 1 {
+			t.Fatalf("\t不满足目标值的nonce被接受")
+		}
+		resp = callTestRPC(t, "submitheader", tpl.WorkID, good)
+		if resp.Error != nil {
+			t.Fatalf("\t提交求解后的区块头失败:%s", resp.Error.Message)
+		}
+		if bc.GetLastBlockHeight() != 2 || bc.GetBlockHashByHeight(2) == nil {
+			t.Fatalf("\t提交区块头后最新高度为%d", bc.GetLastBlockHeight())
+		}
+		//模板已过期或不存在
+		resp = callTestRPC(t, "submitheader", "00", good)
+		if resp.Error == nil {
+			t.Fatalf("\t找不到模板的区块头被接受")
+		}
+		t.Log("\t区块头提交后主链已延长")
+	}
+}
+
+func TestRPCSubmitBlock(t *testing.T) {
+	t.Log("测试外部矿工求解区块模板后通过submitblock提交完整区块")
+	{
+		newTestRPCChain(t)
+		bc := block.NewBlockchain()
+		tpl := getTestTemplate(t)
+		good, _ := solveTestTemplate(t, tpl)
+		b := *workTemplates[tpl.WorkID]
+		b.Nonce = good
+		b.Hash = b.CalcHash()
+		//修改奖励金额后默克尔根与区块内容不一致
+		tampered := b
+		tampered.Transactions = []block.Transaction{b.Transactions[0]}
+		tampered.Transactions[0].Vout = []block.TXOutput{{Value: 1, ScriptPubKey: b.Transactions[0].Vout[0].ScriptPubKey}}
+		resp := callTestRPC(t, "submitblock", hex.EncodeToString(tampered.Serialize()))
+		if resp.Error == nil || bc.GetLastBlockHeight() != 1 {
+			t.Fatalf("\t内容被修改的区块被接受")
+		}
+		resp = callTestRPC(t, "submitblock", hex.EncodeToString(b.Serialize()))
+		if resp.Error != nil {
+			t.Fatalf("\t提交求解后的区块失败:%s", resp.Error.Message)
+		}
+		if !bytes.Equal(bc.GetBlockHashByHeight(2), b.Hash) {
+			t.Fatalf("\t提交区块后主链高度2的区块不是提交的区块")
+		}
+		//基于本地没有的上一个区块挖出的区块只能暂存入孤块池
+		stale := b
+		stale.PreHash = bytes.Repeat([]byte{1}, 32)
+		stale.Height = 3
+		stale.Hash = stale.CalcHash()
+		resp = callTestRPC(t, "submitblock", hex.EncodeToString(stale.Serialize()))
+		if resp.Error == nil || bc.GetLastBlockHeight() != 2 {
+			t.Fatalf("\t找不到上一个区块的区块没有返回错误")
+		}
+		t.Log("\t区块提交后主链已延长,无效区块返回错误")
+	}
+}
+
+func TestRPCMalformedRequest(t *testing.T) {
+	t.Log("测试格式错误的请求返回json-rpc错误")
+	{
+		newTestRPCChain(t)
+		resp := postTestRPC(t, "{")
+		if resp.Error == nil || resp.Error.Code != -32700 {
+			t.Fatalf("\t无法解析的请求没有返回-32700错误")
+		}
+		requests := []struct {
+			method string
+			params []interface{}
+		}{
+			{"unknown", nil},
+			{"getblocktemplate", []interface{}{1}},
+			{"submitblock", nil},
+			{"submitblock", []interface{}{"zz"}},
+			{"submitblock", []interface{}{"00ff"}},
+			{"submitheader", []interface{}{"00"}},
+			{"submitheader", []interface{}{"00", "nonce"}},
+			{"submitheader", []interface{}{"00", 1, "time"}},
+		}
+		for _, r := range requests {
+			resp = callTestRPC(t, r.method, r.params...)
+			if resp.Error == nil {
+				t.Fatalf("\t%s%v没有返回错误", r.method, r.params)
+			}
+		}
+		w := httptest.NewRecorder()
+		handleRPC(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("\tGET请求返回了%d", w.Code)
+		}
+		t.Log("\t格式错误的请求都返回了错误")
+	}
+}
@@ -16,16 +16,16 @@ func (s Send) SendSignOutToPeers() {
 	ss := "节点:" + localAddr + "已退出网络"
 	m := myerror{ss, localAddr}
 	data := jointMessage(cMyError, m.serialize())
-	for _, v := range peerPool {
+	for _, v := range getPeers() {
 		s.SendMessage(v, data)
 	}
 }
 
 //向网络中其他节点发送高度信息
 func (s Send) SendVersionToPeers(lastHeight int) {
-	newV := version{versionInfo, lastHeight, localAddr}
+	newV := version{versionInfo, block.ActiveParams.ChainID, lastHeight, localAddr}
 	data := jointMessage(cVersion, newV.serialize())
-	for _, v := range peerPool {
+	for _, v := range getPeers() {
 		s.SendMessage(v, data)
 	}
 	log.Trace("version信息发送完毕...")
@@ -33,21 +33,19 @@ func (s Send) SendVersionToPeers(lastHeight int) {
 
 //向网络中其他节点发送交易信息
 func (s Send) SendTransToPeers(ts []block.Transaction) {
-	//向交易信息列表加入节点地址信息
-	nts := make([]Transaction, len(ts))
-	for i := range ts {
-		nts[i].TxHash = ts[i].TxHash
-		nts[i].Vout = ts[i].Vout
-		nts[i].Vint = ts[i].Vint
-		nts[i].AddrFrom = localAddr
-	}
-	tss := Transactions{nts}
+	tss := newTransactions(ts)
 	//开启一个go程,先传送给自己进行处理
 	go handleTransaction(tss.Serialize())
-	//然后将命令与交易列表拼接好发送给全网节点
+	//然后将交易列表发送给全网节点
+	s.broadcastTransactions(tss)
+}
+
+//只向网络中其他节点发送交易信息,用于重新广播交易池中尚未打包的交易
+func (s Send) broadcastTransactions(tss Transactions) {
+	//将命令与交易列表拼接好发送给全网节点
 	data := jointMessage(cTransaction, tss.Serialize())
 	log.Tracef("准备发送%d笔交易到网络中其他P2P节点", len(tss.Ts))
-	for _, v := range peerPool {
+	for _, v := range getPeers() {
 		s.SendMessage(v, data)
 	}
 }
@@ -59,7 +57,7 @@ func (Send) SendMessage(peer peer.AddrInfo, data []byte) {
 		log.Error("Connection failed:", err)
 	}
 	//打开一个流，向流写入信息后关闭
-	stream, err := localHost.NewStream(ctx, peer.ID, protocol.ID(ProtocolID))
+	stream, err := localHost.NewStream(ctx, peer.ID, protocol.ID(block.ActiveParams.ProtocolID))
 	if err != nil {
 		log.Debug("Stream open failed", err)
 	} else {
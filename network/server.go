@@ -13,6 +13,7 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -20,6 +21,12 @@ import (
 //在P2P网络中已发现的节点池
 //key:节点ID  value:节点详细信息
 var peerPool = make(map[string]peer.AddrInfo)
+
+//因链id不同而被拒绝的节点,再次发现时不加入节点池
+var refusedPeers = make(map[string]bool)
+
+//节点池与被拒绝的节点会被多个go程同时读写,需要加锁
+var peerLock = sync.RWMutex{}
 var ctx = context.Background()
 var send = Send{}
 
@@ -27,8 +34,21 @@ var send = Send{}
 func StartNode(clier Clier) {
 	//先获取本地区块最新高度
 	bc := block.NewBlockchain()
+	//交易池跟随主链变化移出已打包的交易
+	block.RegisterChainListener(txPool)
+	//检查utxo数据库是否因上次异常退出而与主链不一致
+	bc.RecoverUTXO()
+	//补上旧版本数据库没有的主链高度索引
+	bc.RecoverHeightIndex()
+	//写入或检查当前网络的创世区块
+	err := bc.InitGenesisBlock()
+	if err != nil {
+		log.Fatal(err)
+	}
+	//载入上次退出时尚未打包的交易
+	txPool.Load()
 	block.NewestBlockHeight = bc.GetLastBlockHeight()
-	log.Infof("[*] 监听IP地址: %s 端口号: %s", ListenHost, ListenPort)
+	log.Infof("[*] 网络类型: %s 监听IP地址: %s 端口号: %s", block.ActiveParams.Name, ListenHost, ListenPort)
 	r := rand.Reader
 	// 为本地节点创建RSA密钥对
 	prvKey, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
@@ -52,13 +72,21 @@ func StartNode(clier Clier) {
 	localAddr = fmt.Sprintf("/ip4/%s/tcp/%s/p2p/%s", ListenHost, ListenPort, host.ID().Pretty())
 	log.Infof("[*] 你的P2P地址信息: %s", localAddr)
 	//启动监听本地端口，并且传入一个处理流的函数，当本地节点接收到流的时候回调处理流的函数
-	host.SetStreamHandler(protocol.ID(ProtocolID), handleStream)
+	host.SetStreamHandler(protocol.ID(block.ActiveParams.ProtocolID), handleStream)
 	//寻找p2p网络并加入到节点池里
 	go findP2PPeer()
 	//监测节点池,如果发现网络当中节点有变动则打印到屏幕
 	go monitorP2PNodes()
 	//启一个go程去向其他p2p节点发送高度信息，来进行更新区块数据
 	go sendVersionToPeers()
+	//定期重新广播交易池中尚未打包的交易
+	go rebroadcastTransactions()
+	//按出块间隔与区块大小上限出块
+	go produceBlocks()
+	//启动外部矿工使用的json-rpc服务
+	go startRPCServer()
+	//启动局域网矿机使用的Stratum矿池服务
+	go startStratumServer()
 	//启动程序的命令行输入环境
 	go clier.ReceiveCMD()
 	fmt.Println("本地网络节点已启动,详细信息请查看log日志!")
@@ -67,22 +95,63 @@ func StartNode(clier Clier) {
 
 //启动mdns寻找p2p网络 并等节点连接
 func findP2PPeer() {
-	peerChan := initMDNS(ctx, localHost, RendezvousString)
+	peerChan := initMDNS(ctx, localHost, block.ActiveParams.RendezvousString)
 	for {
 		peer := <-peerChan // will block untill we discover a peer
-		//将发现的节点加入节点池
-		peerPool[fmt.Sprint(peer.ID)] = peer
+		//将发现的节点加入节点池,被拒绝的节点除外
+		peerLock.Lock()
+		if !refusedPeers[fmt.Sprint(peer.ID)] {
+			peerPool[fmt.Sprint(peer.ID)] = peer
+		}
+		peerLock.Unlock()
+	}
+}
+
+//获取节点池中的全部节点
+func getPeers() []peer.AddrInfo {
+	peerLock.RLock()
+	defer peerLock.RUnlock()
+	peers := []peer.AddrInfo{}
+	for _, v := range peerPool {
+		peers = append(peers, v)
 	}
+	return peers
+}
+
+//获取节点池中的节点数量
+func peerCount() int {
+	peerLock.RLock()
+	defer peerLock.RUnlock()
+	return len(peerPool)
+}
+
+//将节点移出节点池
+func removePeer(id string) {
+	peerLock.Lock()
+	defer peerLock.Unlock()
+	delete(peerPool, id)
+}
+
+//拒绝链id不同的节点:通知对方后将其移出节点池,之后也不再加入
+func refusePeer(addr string) {
+	peer := buildPeerInfoByAddr(addr)
+	m := myerror{"节点:" + localAddr + "的链id与你不同,已拒绝连接", localAddr}
+	send.SendMessage(peer, jointMessage(cMyError, m.serialize()))
+	peerLock.Lock()
+	defer peerLock.Unlock()
+	refusedPeers[fmt.Sprint(peer.ID)] = true
+	delete(peerPool, fmt.Sprint(peer.ID))
 }
 
 //一个监测程序,监测当前网络中已发现的节点
 func monitorP2PNodes() {
 	currentPeerPoolNum := 0
 	for {
-		peerPoolNum := len(peerPool)
+		peers := getPeers()
+		peerPoolNum := len(peers)
 		if peerPoolNum != currentPeerPoolNum && peerPoolNum != 0 {
 			log.Info("----------------------检测到网络中P2P节点变动,当前节点池存在的节点------------------")
-			for _, v := range peerPool {
+			for _, v := range peers {
 				log.Info("|   ", v, "   |")
 			}
 			log.Info("----------------------------------------------------------------------------------")
@@ -99,7 +168,7 @@ func monitorP2PNodes() {
 func sendVersionToPeers() {
 	//如果节点池中还未存在节点的话,一直循环 直到发现已连接节点
 	for {
-		if len(peerPool) == 0 {
+		if peerCount() == 0 {
 			time.Sleep(time.Second)
 			continue
 		} else {
@@ -109,6 +178,36 @@ func sendVersionToPeers() {
 	send.SendVersionToPeers(block.NewestBlockHeight)
 }
 
+//定期移出超时的交易,并将交易池中尚未打包的交易重新广播给其他节点
+func rebroadcastTransactions() {
+	for {
+		time.Sleep(time.Second * time.Duration(RebroadcastInterval))
+		txPool.Expire()
+		ts := txPool.Transactions()
+		if len(ts) == 0 || peerCount() == 0 {
+			continue
+		}
+		log.Debugf("重新广播交易池中%d笔尚未打包的交易", len(ts))
+		//按区块大小上限分批发送,保证每条消息都不超过接收方的大小上限
+		batch := []block.Transaction{}
+		size := 0
+		for _, t := range ts {
+			if len(batch) > 0 && size+t.Size() > block.MaxBlockSize {
+				send.broadcastTransactions(newTransactions(batch))
+				batch, size = []block.Transaction{}, 0
+			}
+			batch = append(batch, t)
+			size += t.Size()
+		}
+		send.broadcastTransactions(newTransactions(batch))
+	}
+}
+
+//获取交易池中尚未打包的交易
+func GetPoolTransaction(hash []byte) (block.Transaction, bool) {
+	return txPool.Get(hash)
+}
+
 //节点退出信号处理
 func signalHandle() {
 	sigs := make(chan os.Signal, 1)
@@ -0,0 +1,437 @@
+/*
+	Stratum v1矿池服务:局域网内的多台矿机连接到同一个节点共同挖矿
+	节点根据当前最新区块与交易池生成挖矿任务(奖励交易支付给本节点的挖矿奖励地址),
+	矿工以低于区块难度的份额难度提交份额,节点按矿工统计份额用于分配收益,
+	份额同时满足区块难度时,节点重建完整区块并与网络中接收到的区块经过同样的校验后加入区块链
+
+	与比特币Stratum的区别(区块头格式与默克尔树规则不同):
+	1.mining.notify参数为 [job_id, prevhash, coinb1, coinb2, merkle_branch, version, nbits, ntime, clean_jobs, height]
+	2.奖励交易hash = sha256(sha256(coinb1 + extranonce1 + extranonce2 + coinb2))
+	  奖励交易叶节点 = 奖励交易hash + sha256(coinb1的最后16字节 + extranonce1 + extranonce2)
+	3.默克尔根 = 从sha256(0x00 + 叶节点)开始,依次计算sha256(0x01 + 当前hash + 分支hash)
+	4.区块头 = version(8) + prevhash + 默克尔根 + ntime(8) + nbits(8) + nonce(8) + height(8),整数均为大端,区块hash = sha256(区块头)
+	5.mining.submit参数为 [worker, job_id, extranonce2, ntime, nonce],ntime与nonce为8字节大端的十六进制
+*/
+package network
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	blc "github.com/corgi-kx/blockchain_golang/blc"
+	"github.com/corgi-kx/blockchain_golang/mempool"
+	"github.com/corgi-kx/blockchain_golang/util"
+	log "github.com/corgi-kx/logcustom"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+//Stratum监听地址,为空时不启动
+var StratumListen = ""
+
+//矿工的份额难度
+var StratumShareDifficulty float64 = 1
+
+//没有新区块时重新生成挖矿任务的间隔(秒),以便打包交易池中新到的交易
+var StratumJobInterval = 30
+
+//每个矿工连接分配的extranonce1字节数
+const extraNonce1Size = 4
+
+//矿工自己选择的extranonce2字节数
+const extraNonce2Size = 4
+
+//最多保留的挖矿任务数量
+const maxStratumJobs = 8
+
+//挖矿任务
+type stratumJob struct {
+	id     string
+	block  *blc.Block
+	coinb1 []byte
+	coinb2 []byte
+	branch [][]byte
+	//已提交过的份额,防止重复提交
+	submitted map[string]bool
+}
+
+//矿工的份额统计
+type WorkerShares struct {
+	//有效份额数量
+	Accepted int
+	//无效份额数量
+	Rejected int
+	//有效份额的难度之和,用于按工作量分配收益
+	Work float64
+	//挖到的区块数量
+	Blocks int
+	//最后一次提交有效份额的时间
+	LastShare int64
+}
+
+//矿工连接
+type stratumConn struct {
+	conn        net.Conn
+	writeLock   sync.Mutex
+	extraNonce1 []byte
+	//已授权的矿工名
+	workers map[string]bool
+}
+
+type stratumServer struct {
+	lock   sync.Mutex
+	conns  map[*stratumConn]bool
+	jobs   map[string]*stratumJob
+	order  []string
+	jobSeq uint64
+	//下一个分配给矿工连接的extranonce1
+	nextExtraNonce1 uint32
+	shares          map[string]*WorkerShares
+}
+
+var stratum = &stratumServer{
+	conns:  map[*stratumConn]bool{},
+	jobs:   map[string]*stratumJob{},
+	shares: map[string]*WorkerShares{},
+}
+
+type stratumRequest struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type stratumResponse struct {
+	ID     interface{} `json:"id"`
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+}
+
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+//获取各矿工的份额统计,用于分配收益
+func GetWorkerShares() map[string]WorkerShares {
+	stratum.lock.Lock()
+	defer stratum.lock.Unlock()
+	result := map[string]WorkerShares{}
+	for k, v := range stratum.shares {
+		result[k] = *v
+	}
+	return result
+}
+
+//启动Stratum矿池服务
+func startStratumServer() {
+	if StratumListen == "" {
+		return
+	}
+	if blc.Engine.Name() != "pow" {
+		log.Error("当前共识不是工作量证明,不启动Stratum矿池服务")
+		return
+	}
+	listener, err := net.Listen("tcp", StratumListen)
+	if err != nil {
+		log.Errorf("Stratum矿池服务启动失败:%s", err)
+		return
+	}
+	log.Infof("[*] Stratum矿池监听地址: %s,份额难度:%v", StratumListen, StratumShareDifficulty)
+	//新区块接入主链后立即下发新的挖矿任务
+	blc.RegisterChainListener(stratum)
+	go stratum.refreshJobs()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Warnf("Stratum接收连接失败:%s", err)
+			continue
+		}
+		go stratum.serve(conn)
+	}
+}
+
+func (s *stratumServer) BlockConnected(b *blc.Block) {
+	go s.newJob(true)
+}
+
+func (s *stratumServer) BlockDisconnected(b *blc.Block) {}
+
+//定时生成新的挖矿任务,打包交易池中新到的交易
+func (s *stratumServer) refreshJobs() {
+	for {
+		time.Sleep(time.Second * time.Duration(StratumJobInterval))
+		s.newJob(false)
+	}
+}
+
+//根据当前最新区块与交易池生成挖矿任务并下发给全部矿工,cleanJobs为true时之前的任务全部作废
+func (s *stratumServer) newJob(cleanJobs bool) {
+	job, err := buildStratumJob()
+	if err != nil {
+		log.Warnf("Stratum生成挖矿任务失败:%s", err)
+		return
+	}
+	s.lock.Lock()
+	if cleanJobs {
+		s.jobs = map[string]*stratumJob{}
+		s.order = []string{}
+	}
+	s.jobSeq++
+	job.id = fmt.Sprintf("%x", s.jobSeq)
+	s.jobs[job.id] = job
+	s.order = append(s.order, job.id)
+	for len(s.order) > maxStratumJobs {
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+	conns := []*stratumConn{}
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.lock.Unlock()
+	for _, c := range conns {
+		c.notify("mining.notify", job.notifyParams(cleanJobs)...)
+	}
+}
+
+//获取最新的挖矿任务,还没有任务时生成一个
+func (s *stratumServer) currentJob() *stratumJob {
+	s.lock.Lock()
+	if len(s.order) != 0 {
+		job := s.jobs[s.order[len(s.order)-1]]
+		s.lock.Unlock()
+		return job
+	}
+	s.lock.Unlock()
+	s.newJob(true)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.order) == 0 {
+		return nil
+	}
+	return s.jobs[s.order[len(s.order)-1]]
+}
+
+func buildStratumJob() (*stratumJob, error) {
+	bc := blc.NewBlockchain()
+	address := bc.GetRewardAddress()
+	if address == "" {
+		return nil, errors.New("没有设置挖矿奖励地址")
+	}
+	b, err := bc.NewBlockTemplate(txPool.BlockTemplate(mempool.BlockMaxSize), address)
+	if err != nil {
+		return nil, err
+	}
+	coinb1, coinb2, err := b.SplitCoinbase(extraNonce1Size + extraNonce2Size)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := b.CoinbaseMerkleBranch()
+	if err != nil {
+		return nil, err
+	}
+	return &stratumJob{"", b, coinb1, coinb2, branch, map[string]bool{}}, nil
+}
+
+func (j *stratumJob) notifyParams(cleanJobs bool) []interface{} {
+	branch := []string{}
+	for _, h := range j.branch {
+		branch = append(branch, hex.EncodeToString(h))
+	}
+	return []interface{}{
+		j.id,
+		hex.EncodeToString(j.block.PreHash),
+		hex.EncodeToString(j.coinb1),
+		hex.EncodeToString(j.coinb2),
+		branch,
+		hex.EncodeToString(util.Int64ToBytes(int64(j.block.Version))),
+		hex.EncodeToString(util.Int64ToBytes(int64(j.block.Bits))),
+		hex.EncodeToString(util.Int64ToBytes(j.block.TimeStamp)),
+		cleanJobs,
+		j.block.Height,
+	}
+}
+
+//处理一个矿工连接,每行一条json消息
+func (s *stratumServer) serve(conn net.Conn) {
+	s.lock.Lock()
+	s.nextExtraNonce1++
+	c := &stratumConn{conn: conn, extraNonce1: util.Int64ToBytes(int64(s.nextExtraNonce1))[8-extraNonce1Size:], workers: map[string]bool{}}
+	s.conns[c] = true
+	s.lock.Unlock()
+	log.Infof("Stratum矿工已连接:%s", conn.RemoteAddr())
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, c)
+		s.lock.Unlock()
+		conn.Close()
+		log.Infof("Stratum矿工已断开:%s", conn.RemoteAddr())
+	}()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		req := stratumRequest{}
+		err := json.Unmarshal(scanner.Bytes(), &req)
+		if err != nil {
+			log.Debugf("Stratum消息格式错误:%s", err)
+			return
+		}
+		result, err := s.handle(c, &req)
+		resp := stratumResponse{req.ID, result, nil}
+		if err != nil {
+			resp.Error = []interface{}{20, err.Error(), nil}
+		}
+		c.write(resp)
+		//订阅后下发份额难度与当前挖矿任务
+		if req.Method == "mining.subscribe" && err == nil {
+			c.notify("mining.set_difficulty", StratumShareDifficulty)
+			if job := s.currentJob(); job != nil {
+				c.notify("mining.notify", job.notifyParams(true)...)
+			}
+		}
+	}
+}
+
+func (s *stratumServer) handle(c *stratumConn, req *stratumRequest) (interface{}, error) {
+	switch req.Method {
+	case "mining.subscribe":
+		subscription := hex.EncodeToString(c.extraNonce1)
+		return []interface{}{
+			[][]string{{"mining.set_difficulty", subscription}, {"mining.notify", subscription}},
+			hex.EncodeToString(c.extraNonce1),
+			extraNonce2Size,
+		}, nil
+	case "mining.authorize":
+		var worker string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &worker) != nil || worker == "" {
+			return false, errors.New("矿工名不能为空")
+		}
+		c.workers[worker] = true
+		log.Infof("Stratum矿工%s已授权", worker)
+		return true, nil
+	case "mining.submit":
+		params := make([]string, 5)
+		if len(req.Params) < 5 {
+			return false, errors.New("参数应为 [worker, job_id, extranonce2, ntime, nonce]")
+		}
+		for i := range params {
+			if json.Unmarshal(req.Params[i], &params[i]) != nil {
+				return false, errors.New("参数应为字符串")
+			}
+		}
+		if !c.workers[params[0]] {
+			return false, errors.New("矿工未授权")
+		}
+		err := s.submit(c, params[0], params[1], params[2], params[3], params[4])
+		s.recordShare(params[0], err == nil)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	default:
+		return nil, fmt.Errorf("不支持的方法:%s", req.Method)
+	}
+}
+
+//校验矿工提交的份额,份额同时满足区块难度时提交完整区块
+func (s *stratumServer) submit(c *stratumConn, worker, jobID, extraNonce2Hex, nTimeHex, nonceHex string) error {
+	extraNonce2, err := hex.DecodeString(extraNonce2Hex)
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		return errors.New("extranonce2格式错误")
+	}
+	nTime, err := hex.DecodeString(nTimeHex)
+	if err != nil || len(nTime) != 8 {
+		return errors.New("ntime格式错误")
+	}
+	nonce, err := hex.DecodeString(nonceHex)
+	if err != nil || len(nonce) != 8 {
+		return errors.New("nonce格式错误")
+	}
+	s.lock.Lock()
+	job, ok := s.jobs[jobID]
+	key := hex.EncodeToString(c.extraNonce1) + extraNonce2Hex + nTimeHex + nonceHex
+	duplicate := ok && job.submitted[key]
+	if ok {
+		job.submitted[key] = true
+	}
+	s.lock.Unlock()
+	if !ok {
+		return errors.New("挖矿任务已过期")
+	}
+	if duplicate {
+		return errors.New("重复提交的份额")
+	}
+	//在任务的区块模板上重建矿工挖到的区块
+	b := *job.block
+	err = b.SetCoinbaseExtraNonce(append(append([]byte{}, c.extraNonce1...), extraNonce2...))
+	if err != nil {
+		return err
+	}
+	b.TimeStamp = int64(util.BytesToInt(nTime))
+	b.Nonce = int64(util.BytesToInt(nonce))
+	b.Hash = b.CalcHash()
+	hashInt := new(big.Int).SetBytes(b.Hash)
+	shareTarget := blc.DifficultyToTarget(StratumShareDifficulty)
+	blockTarget := blc.CompactToBig(b.Bits)
+	if hashInt.Cmp(shareTarget) >= 0 && hashInt.Cmp(blockTarget) >= 0 {
+		return errors.New("份额不满足难度要求")
+	}
+	if hashInt.Cmp(blockTarget) >= 0 {
+		return nil
+	}
+	log.Infof("Stratum矿工%s挖到了区块,高度%d,hash为%x", worker, b.Height, b.Hash)
+	isOrphan, err := processBlock(&b)
+	if err != nil {
+		log.Errorf("Stratum矿工%s挖到的区块%x没有通过校验:%s", worker, b.Hash, err)
+		return err
+	}
+	if !isOrphan {
+		s.lock.Lock()
+		s.workerShares(worker).Blocks++
+		s.lock.Unlock()
+		send.SendVersionToPeers(b.Height)
+	}
+	return nil
+}
+
+func (s *stratumServer) workerShares(worker string) *WorkerShares {
+	ws, ok := s.shares[worker]
+	if !ok {
+		ws = &WorkerShares{}
+		s.shares[worker] = ws
+	}
+	return ws
+}
+
+func (s *stratumServer) recordShare(worker string, accepted bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ws := s.workerShares(worker)
+	if !accepted {
+		ws.Rejected++
+		return
+	}
+	ws.Accepted++
+	ws.Work += StratumShareDifficulty
+	ws.LastShare = time.Now().Unix()
+}
+
+func (c *stratumConn) write(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.conn.Write(append(data, '\n'))
+}
+
+func (c *stratumConn) notify(method string, params ...interface{}) {
+	c.write(stratumNotification{nil, method, params})
+}
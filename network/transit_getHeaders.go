@@ -6,14 +6,13 @@ import (
 	log "github.com/corgi-kx/logcustom"
 )
 
-type hashMap map[int][]byte
-
-type hash struct {
-	HashMap  hashMap
+type getHeaders struct {
+	//区块定位器,用于对方找到与本地链的分叉点
+	Locator  [][]byte
 	AddrFrom string
 }
 
-func (v hash) serialize() []byte {
+func (v getHeaders) serialize() []byte {
 	var result bytes.Buffer
 	encoder := gob.NewEncoder(&result)
 
@@ -24,7 +23,7 @@ func (v hash) serialize() []byte {
 	return result.Bytes()
 }
 
-func (v *hash) deserialize(d []byte) {
+func (v *getHeaders) deserialize(d []byte) {
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(v)
 	if err != nil {
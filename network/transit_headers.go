@@ -3,15 +3,16 @@ package network
 import (
 	"bytes"
 	"encoding/gob"
+	block "github.com/corgi-kx/blockchain_golang/blc"
 	log "github.com/corgi-kx/logcustom"
 )
 
-type getHash struct {
-	Height   int
+type headers struct {
+	Headers  []block.BlockHeader
 	AddrFrom string
 }
 
-func (v getHash) serialize() []byte {
+func (v headers) serialize() []byte {
 	var result bytes.Buffer
 	encoder := gob.NewEncoder(&result)
 
@@ -22,7 +23,7 @@ func (v getHash) serialize() []byte {
 	return result.Bytes()
 }
 
-func (v *getHash) deserialize(d []byte) {
+func (v *headers) deserialize(d []byte) {
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(v)
 	if err != nil {
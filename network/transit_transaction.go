@@ -17,10 +17,30 @@ type Transaction struct {
 	Vint []block.TXInput
 	//UTXO输出
 	Vout []block.TXOutput
+	//绝对时间锁
+	LockTime int64
 
 	AddrFrom string
 }
 
+//将blc下的transaction转换为network下的transaction,并加入节点地址信息
+func newTransactions(ts []block.Transaction) Transactions {
+	nts := make([]Transaction, len(ts))
+	for i := range ts {
+		nts[i].TxHash = ts[i].TxHash
+		nts[i].Vout = ts[i].Vout
+		nts[i].Vint = ts[i].Vint
+		nts[i].LockTime = ts[i].LockTime
+		nts[i].AddrFrom = localAddr
+	}
+	return Transactions{nts}
+}
+
+//将network下的transaction转换为blc下的transaction
+func (t *Transaction) toBlc() block.Transaction {
+	return block.Transaction{TxHash: t.TxHash, Vint: t.Vint, Vout: t.Vout, LockTime: t.LockTime}
+}
+
 func (t *Transactions) Serialize() []byte {
 	var result bytes.Buffer
 	encoder := gob.NewEncoder(&result)
@@ -7,7 +7,9 @@ import (
 )
 
 type version struct {
-	Version  byte
+	Version byte
+	//链id,与本节点不同的节点会被拒绝
+	ChainID  uint32
 	Height   int
 	AddrFrom string
 }
@@ -1,6 +1,8 @@
 package network
 
 import (
+	"bytes"
+	block "github.com/corgi-kx/blockchain_golang/blc"
 	log "github.com/corgi-kx/logcustom"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multiaddr"
@@ -28,19 +30,24 @@ func buildPeerInfoByAddr(addrs string) peer.AddrInfo {
 	return peer.AddrInfo{peer.ID(id), m}
 }
 
-//默认前十二位为命令名称
+//前四位为当前网络的魔数,之后十二位为命令名称
 func jointMessage(cmd command, content []byte) []byte {
-	b := make([]byte, prefixCMDLength)
+	b := make([]byte, prefixMagicLength+prefixCMDLength)
+	copy(b, block.ActiveParams.NetMagic[:])
 	for i, v := range []byte(cmd) {
-		b[i] = v
+		b[prefixMagicLength+i] = v
 	}
 	joint := make([]byte, 0)
 	joint = append(b, content...)
 	return joint
 }
 
-//默认前十二位为命令名称
+//前四位为网络魔数,之后十二位为命令名称,魔数与当前网络不一致时返回空命令
 func splitMessage(message []byte) (cmd string, content []byte) {
+	if len(message) < prefixMagicLength+prefixCMDLength || !bytes.Equal(message[:prefixMagicLength], block.ActiveParams.NetMagic[:]) {
+		return
+	}
+	message = message[prefixMagicLength:]
 	cmdBytes := message[:prefixCMDLength]
 	newCMDBytes := make([]byte, 0)
 	for _, v := range cmdBytes {
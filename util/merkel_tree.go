@@ -1,14 +1,27 @@
 /*
 	默克尔树
+	叶节点与中间节点在hash前分别加上不同的前缀,奇数个节点时最后一个节点直接提升到上一层而不是复制一份,
+	这样不同的交易列表不会得到相同的默克尔根(避免CVE-2012-2459中通过重复交易伪造区块的问题)
 */
 package util
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"errors"
+)
+
+const (
+	//叶节点hash前缀
+	merkelLeafPrefix = byte(0x00)
+	//中间节点hash前缀
+	merkelNodePrefix = byte(0x01)
 )
 
 type MerkelTree struct {
 	MerkelRootNode *MerkelNode
+	//每一层的节点,第0层为叶节点,最后一层为根节点
+	levels [][]MerkelNode
 }
 
 type MerkelNode struct {
@@ -17,44 +30,94 @@ type MerkelNode struct {
 	Data  []byte
 }
 
+//默克尔证明中的一个节点
+type MerkelProofNode struct {
+	//兄弟节点的hash
+	Hash []byte
+	//兄弟节点是否在左边
+	IsLeft bool
+}
+
 func NewMerkelTree(data [][]byte) *MerkelTree {
-	//首先需要知道data的数量，如果是奇数需要在结尾拷贝一份凑成偶数
-	if len(data)%2 != 0 {
-		data = append(data, data[len(data)-1])
-	}
 	//将普通交易计算成默克尔树最远叶节点，保存到切片里
 	nodes := []MerkelNode{}
 	for i := 0; i < len(data); i++ {
 		mn := BuildMerkelNode(nil, nil, data[i])
 		nodes = append(nodes, mn)
 	}
+	levels := [][]MerkelNode{nodes}
 	//循环获得根节点
-	for {
-		if len(nodes) == 1 {
-			break
-		}
+	for len(nodes) > 1 {
 		newNotes := []MerkelNode{}
-		for i := 0; i < len(nodes); i = i + 2 {
+		for i := 0; i+1 < len(nodes); i = i + 2 {
 			mn := BuildMerkelNode(&nodes[i], &nodes[i+1], nil)
 			newNotes = append(newNotes, mn)
 		}
-		nodes = newNotes
-		//防止奇数叶节点
-		if len(nodes) != 1 && len(nodes)%2 != 0 {
-			nodes = append(nodes, nodes[len(nodes)-1])
+		//奇数个节点时最后一个节点直接提升到上一层
+		if len(nodes)%2 != 0 {
+			newNotes = append(newNotes, nodes[len(nodes)-1])
 		}
+		nodes = newNotes
+		levels = append(levels, nodes)
+	}
+	if len(nodes) == 0 {
+		return &MerkelTree{nil, levels}
 	}
-	return &MerkelTree{&nodes[0]}
+	return &MerkelTree{&nodes[0], levels}
 }
 
 func BuildMerkelNode(left, right *MerkelNode, data []byte) MerkelNode {
 	if left == nil && right == nil {
-		datum := sha256.Sum256(data)
-		mn := MerkelNode{nil, nil, datum[:]}
-		return mn
+		return MerkelNode{nil, nil, hashMerkelLeaf(data)}
 	}
-	sumData := append(left.Data, right.Data...)
-	finalData := sha256.Sum256(sumData)
-	mn := MerkelNode{left, right, finalData[:]}
+	mn := MerkelNode{left, right, hashMerkelNode(left.Data, right.Data)}
 	return mn
 }
+
+//计算中间节点hash
+func hashMerkelNode(left, right []byte) []byte {
+	sumData := []byte{merkelNodePrefix}
+	sumData = append(sumData, left...)
+	sumData = append(sumData, right...)
+	finalData := sha256.Sum256(sumData)
+	return finalData[:]
+}
+
+//计算叶节点hash
+func hashMerkelLeaf(data []byte) []byte {
+	datum := sha256.Sum256(append([]byte{merkelLeafPrefix}, data...))
+	return datum[:]
+}
+
+//生成第index个叶节点到根节点的默克尔证明
+func (mt *MerkelTree) GenerateProof(index int) ([]MerkelProofNode, error) {
+	if index < 0 || len(mt.levels) == 0 || index >= len(mt.levels[0]) {
+		return nil, errors.New("GenerateProof err : 叶节点索引超出范围")
+	}
+	proof := []MerkelProofNode{}
+	for _, nodes := range mt.levels[:len(mt.levels)-1] {
+		if index%2 == 0 {
+			//本层最后一个奇数节点没有兄弟节点,直接提升到上一层
+			if index+1 < len(nodes) {
+				proof = append(proof, MerkelProofNode{nodes[index+1].Data, false})
+			}
+		} else {
+			proof = append(proof, MerkelProofNode{nodes[index-1].Data, true})
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+//验证叶节点数据leaf是否属于默克尔根为root的默克尔树
+func VerifyProof(leaf []byte, proof []MerkelProofNode, root []byte) bool {
+	current := hashMerkelLeaf(leaf)
+	for _, p := range proof {
+		if p.IsLeft {
+			current = hashMerkelNode(p.Hash, current)
+		} else {
+			current = hashMerkelNode(current, p.Hash)
+		}
+	}
+	return bytes.Equal(current, root)
+}
@@ -65,3 +65,44 @@ func findMK(mn *MerkelNode, findTsHash []byte) *MerkelNode {
 	}
 	return nil
 }
+
+func TestMerkelProof(t *testing.T) {
+	t.Log("测试默克尔证明的生成与验证")
+	{
+		for num := 1; num <= 7; num++ {
+			tss := [][]byte{}
+			for i := 0; i < num; i++ {
+				tss = append(tss, []byte(fmt.Sprintf("第%d条交易", i+1)))
+			}
+			nt := NewMerkelTree(tss)
+			for i := range tss {
+				proof, err := nt.GenerateProof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !VerifyProof(tss[i], proof, nt.MerkelRootNode.Data) {
+					t.Fatalf("\t%d条交易中第%d条交易的默克尔证明验证失败", num, i+1)
+				}
+				if VerifyProof([]byte("不存在的交易"), proof, nt.MerkelRootNode.Data) {
+					t.Fatalf("\t伪造的交易通过了默克尔证明验证")
+				}
+			}
+			if _, err := nt.GenerateProof(num); err == nil {
+				t.Fatalf("\t索引超出范围时应返回错误")
+			}
+		}
+		t.Log("\t默克尔证明验证正确")
+	}
+}
+
+func TestMerkelDuplicateLeaf(t *testing.T) {
+	t.Log("测试重复最后一条交易不会得到相同的默克尔根")
+	{
+		tss := [][]byte{[]byte("第一条交易"), []byte("第二条交易"), []byte("第三条交易")}
+		mutated := append(tss[:3:3], tss[2])
+		if bytes.Equal(NewMerkelTree(tss).MerkelRootNode.Data, NewMerkelTree(mutated).MerkelRootNode.Data) {
+			t.Fatalf("\t重复交易后默克尔根相同")
+		}
+		t.Log("\t默克尔根不同")
+	}
+}